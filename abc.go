@@ -0,0 +1,226 @@
+package midi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var (
+	majorKeyNames = [15]string{"Cb", "Gb", "Db", "Ab", "Eb", "Bb", "F",
+		"C", "G", "D", "A", "E", "B", "F#", "C#"}
+	minorKeyNames = [15]string{"Abm", "Ebm", "Bbm", "Fm", "Cm", "Gm", "Dm",
+		"Am", "Em", "Bm", "F#m", "C#m", "G#m", "D#m", "A#m"}
+)
+
+// keyName returns the ABC name of a key signature.
+func keyName(k KeySignature) string {
+	if k.Sharps < -7 || k.Sharps > 7 {
+		return "C"
+	}
+	if k.Minor {
+		return minorKeyNames[k.Sharps+7]
+	}
+	return majorKeyNames[k.Sharps+7]
+}
+
+// keyAccidentals returns the accidental the key signature applies to each
+// letter, indexed from C to B.
+func keyAccidentals(sharps int) [7]int {
+	var acc [7]int
+	sharpOrder := []int{3, 0, 4, 1, 5, 2, 6} // F C G D A E B
+	for i := 0; i < sharps && i < 7; i++ {
+		acc[sharpOrder[i]] = 1
+	}
+	for i := 0; i < -sharps && i < 7; i++ {
+		acc[sharpOrder[6-i]] = -1
+	}
+	return acc
+}
+
+// spellKey returns the letter (0 for C to 6 for B), accidental and octave
+// of key, preferring flats when useFlats is set.
+func spellKey(key int, useFlats bool) (letter, accidental, octave int) {
+	sharpSpelling := [12][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {2, 0},
+		{3, 0}, {3, 1}, {4, 0}, {4, 1}, {5, 0}, {5, 1}, {6, 0}}
+	flatSpelling := [12][2]int{{0, 0}, {1, -1}, {1, 0}, {2, -1}, {2, 0},
+		{3, 0}, {4, -1}, {4, 0}, {5, -1}, {5, 0}, {6, -1}, {6, 0}}
+	s := sharpSpelling[key%12]
+	if useFlats {
+		s = flatSpelling[key%12]
+	}
+	return s[0], s[1], key/12 - 1
+}
+
+// abcPitch returns the ABC notation of a letter in an octave, where
+// octave 4 is the one starting at middle C.
+func abcPitch(letter, octave int) string {
+	name := "CDEFGAB"[letter]
+	if octave >= 5 {
+		s := string(name + 'a' - 'A')
+		for i := 5; i < octave; i++ {
+			s += "'"
+		}
+		return s
+	}
+	s := string(name)
+	for i := octave; i < 4; i++ {
+		s += ","
+	}
+	return s
+}
+
+// abcLength returns the ABC length suffix for n 64th notes when the unit
+// note length is an eighth.
+func abcLength(n int64) string {
+	num, den := n, int64(8)
+	for den > 1 && num%2 == 0 {
+		num /= 2
+		den /= 2
+	}
+	switch {
+	case den == 1 && num == 1:
+		return ""
+	case den == 1:
+		return fmt.Sprint(num)
+	case num == 1:
+		return fmt.Sprintf("/%d", den)
+	}
+	return fmt.Sprintf("%d/%d", num, den)
+}
+
+// abcSegment is a note (or a rest, if key < 0) between two ticks.
+type abcSegment struct {
+	key        int
+	start, end int64
+}
+
+// ToABC renders a track as ABC notation. The header takes the title from
+// the track name (or the data name), the meter from the first time
+// signature, the key from the first key signature and the tempo from the
+// tempo at tick 0; the unit note length is an eighth note. The track is
+// treated as monophonic: of notes starting together only the highest is
+// kept, and a note is cut short when the next one starts. Timing is
+// rounded to 64th notes, and notes crossing bar lines are tied.
+func (d *MIDIData) ToABC(track int) (string, error) {
+	if track < 0 || track >= len(d.tracks) {
+		return "", fmt.Errorf("invalid track %d", track)
+	}
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return "", errors.New("ABC export requires metrical division")
+	}
+	t := d.tracks[track]
+
+	title := t.Name
+	if title == "" {
+		title = d.Name
+	}
+	ts := d.TimeSignatures()[0]
+	key := KeySignature{}
+	if keys := d.KeySignatures(); len(keys) > 0 {
+		key = keys[0]
+	}
+	bpm := 60000000 / float64(d.MicrosPerQuarterAt(0))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "X:1\nT:%s\nM:%d/%d\nL:1/8\nQ:1/4=%d\nK:%s\n",
+		title, ts.BeatPerBar, ts.BeatUnit, int(math.Floor(bpm+0.5)),
+		keyName(key))
+
+	// Quantize to 64th notes, the shortest beat unit of a time signature,
+	// and reduce to a single line.
+	q := float64(d.Division&0x7FFF) / 16
+	round := func(tick int64) int64 {
+		return int64(math.Floor(float64(tick)/q + 0.5))
+	}
+	notes := t.Notes()
+	sort.SliceStable(notes, func(i, j int) bool {
+		if notes[i].Start != notes[j].Start {
+			return notes[i].Start < notes[j].Start
+		}
+		return notes[i].Key > notes[j].Key
+	})
+	var line []abcSegment
+	for _, n := range notes {
+		start, end := round(n.Start), round(n.End)
+		if l := len(line); l > 0 {
+			prev := &line[l-1]
+			if start <= prev.start {
+				continue
+			}
+			if prev.end > start {
+				prev.end = start
+			}
+		}
+		if end > start {
+			line = append(line, abcSegment{n.Key, start, end})
+		}
+	}
+
+	// Insert rests.
+	var segments []abcSegment
+	var pos int64
+	for _, s := range line {
+		if s.start > pos {
+			segments = append(segments, abcSegment{-1, pos, s.start})
+		}
+		segments = append(segments, s)
+		pos = s.end
+	}
+
+	bar := int64(ts.BeatPerBar) * 64 / int64(ts.BeatUnit)
+	defaults := keyAccidentals(key.Sharps)
+	var state map[[2]int]int
+	bars := 0
+	for _, s := range segments {
+		for start := s.start; start < s.end; {
+			if start%bar == 0 {
+				state = make(map[[2]int]int)
+			}
+			end := (start/bar + 1) * bar
+			if end > s.end {
+				end = s.end
+			}
+
+			if s.key < 0 {
+				buf.WriteString("z")
+			} else {
+				letter, acc, octave := spellKey(s.key, key.Sharps < 0)
+				current, ok := state[[2]int{letter, octave}]
+				if !ok {
+					current = defaults[letter]
+				}
+				if acc != current {
+					buf.WriteString(map[int]string{-1: "_", 0: "=", 1: "^"}[acc])
+					state[[2]int{letter, octave}] = acc
+				}
+				buf.WriteString(abcPitch(letter, octave))
+			}
+			buf.WriteString(abcLength(end - start))
+			if s.key >= 0 && end < s.end {
+				buf.WriteString("-")
+			}
+
+			start = end
+			if start%bar == 0 {
+				bars++
+				if bars%4 == 0 {
+					buf.WriteString(" |\n")
+				} else {
+					buf.WriteString(" | ")
+				}
+			} else {
+				buf.WriteString(" ")
+			}
+		}
+	}
+
+	if len(segments) == 0 {
+		return buf.String(), nil
+	}
+	out := strings.TrimRight(buf.String(), " |\n")
+	return out + " |]\n", nil
+}
@@ -0,0 +1,62 @@
+package midi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToABC(t *testing.T) {
+	melody := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 62, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 66, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 59, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 66, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 59, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x90, 65, 100}},
+		&MIDIEvent{tick: 1200, message: []uint8{0x80, 65, 0}},
+		&MIDIEvent{tick: 1200, message: []uint8{0x90, 65, 100}},
+		&MIDIEvent{tick: 1440, message: []uint8{0x80, 65, 0}},
+		&MIDIEvent{tick: 1440, message: []uint8{0x90, 72, 100}},
+		&MIDIEvent{tick: 3360, message: []uint8{0x80, 72, 0}},
+		&MIDIEvent{tick: 3600, message: []uint8{0x90, 47, 100}},
+		&MIDIEvent{tick: 3720, message: []uint8{0x80, 47, 0}},
+	)
+	melody.Name = "Tune"
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x58, 0x04, 3, 2, 24, 8}},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x59, 0x02, 1, 0}},
+			&MIDIEvent{tick: 0, message: tempoMessage(90)},
+		),
+		melody)
+
+	got, err := d.ToABC(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "X:1\nT:Tune\nM:3/4\nL:1/8\nQ:1/4=90\nK:G\n" +
+		"D2 F2 =F F | c6- | c2 z B,,/2 |]\n"
+	if got != want {
+		t.Errorf("ToABC() =\n%s\nwant\n%s", got, want)
+	}
+
+	if _, err := d.ToABC(2); err == nil {
+		t.Errorf("ToABC with an invalid track should fail")
+	}
+
+	// A bar of 1/64 is a single 64th note.
+	d = newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x58, 0x04, 1, 6, 24, 8}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 60, message: []uint8{0x80, 60, 0}},
+	))
+	got, err = d.ToABC(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "M:1/64\n"; !strings.Contains(got, want) ||
+		!strings.HasSuffix(got, "\nC/8- | C/8 |]\n") {
+		t.Errorf("ToABC() with 1/64 =\n%s", got)
+	}
+}
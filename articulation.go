@@ -0,0 +1,255 @@
+package midi
+
+// nextOnsets maps each note pair to the next note on of the same channel
+// that starts strictly later, or nil if there is none.
+func nextOnsets(pairs []notePair) []*MIDIEvent {
+	next := make([]*MIDIEvent, len(pairs))
+	var pending [16][]int
+	for i, p := range pairs {
+		ch := p.on.message[0] & 0x0F
+		rest := pending[ch][:0]
+		for _, j := range pending[ch] {
+			if pairs[j].on.tick < p.on.tick {
+				next[j] = p.on
+			} else {
+				rest = append(rest, j)
+			}
+		}
+		pending[ch] = append(rest, i)
+	}
+	return next
+}
+
+// Legato extends each note whose release is followed within gapTicks by
+// the next note on the same channel so that it ends exactly where that
+// note starts.
+func (t *MIDITrack) Legato(gapTicks int64) {
+	pairs := t.notePairs()
+	for i, next := range nextOnsets(pairs) {
+		off := pairs[i].off
+		if off == nil || next == nil {
+			continue
+		}
+		if gap := next.tick - off.tick; gap > 0 && gap <= gapTicks {
+			off.tick = next.tick
+		}
+	}
+	t.sortEvents()
+}
+
+// Detach shortens notes so that at least gapTicks separate each note from
+// the next note on the same channel. Notes are never shortened below one
+// tick.
+func (t *MIDITrack) Detach(gapTicks int64) {
+	pairs := t.notePairs()
+	for i, next := range nextOnsets(pairs) {
+		off := pairs[i].off
+		if off == nil || next == nil {
+			continue
+		}
+		if end := next.tick - gapTicks; off.tick > end {
+			if end <= pairs[i].on.tick {
+				end = pairs[i].on.tick + 1
+			}
+			off.tick = end
+		}
+	}
+	t.sortEvents()
+}
+
+// MergeLegato joins notes on the same channel and key where one ends
+// exactly where the next one starts, keeping the velocity of the first,
+// and returns the number of joins made. It is MergeTiedNotes with no
+// tolerance, so it also joins a note re-struck as the previous one ends;
+// use JoinSplits to undo SplitAt exactly.
+func (t *MIDITrack) MergeLegato() int {
+	return t.MergeTiedNotes(0)
+}
+
+// JoinSplits rejoins the pieces of notes split by SplitAt or
+// SplitAtBarLines, leaving other back-to-back notes alone, and returns the
+// number of joins made. A piece whose split note off or note on was
+// removed or moved since is not rejoined.
+func (t *MIDITrack) JoinSplits() int {
+	drop := make(map[*MIDIEvent]bool)
+	joins := 0
+	// Pieces ending in a split note off, by channel, key and tick.
+	open := make(map[[3]int64]*notePair)
+	pairs := t.notePairs()
+	for i := range pairs {
+		p := &pairs[i]
+		ch, key := int64(p.on.message[0]&0x0F), int64(p.on.message[1])
+		if head := open[[3]int64{ch, key, p.on.tick}]; head != nil && p.on.tie {
+			delete(open, [3]int64{ch, key, p.on.tick})
+			joins++
+			drop[head.off], drop[p.on] = true, true
+			head.off = p.off
+			p = head
+		}
+		if p.off != nil && p.off.tie {
+			open[[3]int64{ch, key, p.off.tick}] = p
+		}
+	}
+
+	events := t.events[:0]
+	for _, e := range t.events {
+		if !drop[e] {
+			events = append(events, e)
+		}
+	}
+	t.events = events
+	return joins
+}
+
+// MergeTiedNotes joins consecutive notes on the same channel and key where
+// the next one starts within tolerance ticks of where the previous one
+// ends, as notation programs export tied notes, keeping the velocity of
+// the first. The joined note ends with the later of the two. It returns
+// the number of joins made.
+func (t *MIDITrack) MergeTiedNotes(tolerance int64) int {
+	pairs := t.notePairs()
+	var byKey [16][128][]int
+	for i, p := range pairs {
+		ch, key := p.on.message[0]&0x0F, p.on.message[1]
+		byKey[ch][key] = append(byKey[ch][key], i)
+	}
+
+	drop := make(map[*MIDIEvent]bool)
+	joins := 0
+	for ch := range byKey {
+		for _, indexes := range byKey[ch] {
+			if len(indexes) < 2 {
+				continue
+			}
+			head := &pairs[indexes[0]]
+			for _, i := range indexes[1:] {
+				next := &pairs[i]
+				if head.off == nil || next.on.tick <= head.on.tick ||
+					next.on.tick < head.off.tick-tolerance ||
+					next.on.tick > head.off.tick+tolerance {
+					head = next
+					continue
+				}
+				joins++
+				drop[next.on] = true
+				if next.off == nil || next.off.tick >= head.off.tick {
+					drop[head.off] = true
+					head.off = next.off
+				} else {
+					drop[next.off] = true
+				}
+			}
+		}
+	}
+
+	events := t.events[:0]
+	for _, e := range t.events {
+		if !drop[e] {
+			events = append(events, e)
+		}
+	}
+	t.events = events
+	return joins
+}
+
+// MonoPolicy selects which note Monophonic keeps when notes overlap.
+type MonoPolicy int
+
+const (
+	// MonoHighest keeps the highest of overlapping notes.
+	MonoHighest MonoPolicy = iota
+	// MonoLowest keeps the lowest of overlapping notes.
+	MonoLowest
+	// MonoLast keeps the most recently started note.
+	MonoLast
+)
+
+// Monophonic reduces the track to a single voice across all channels so
+// that no two notes sound at once. Notes are visited in start order: when
+// a note starts while the kept note still sounds, one of them wins by
+// policy. A losing note that started earlier is cut short where the
+// winner starts, and a losing note that started later is removed. Notes
+// cut to zero length are removed as well.
+func (t *MIDITrack) Monophonic(policy MonoPolicy) {
+	drop := make(map[*MIDIEvent]bool)
+	remove := func(p *notePair) {
+		drop[p.on] = true
+		if p.off != nil {
+			drop[p.off] = true
+		}
+	}
+
+	pairs := t.notePairs()
+	var current *notePair
+	for i := range pairs {
+		p := &pairs[i]
+		if current == nil || (current.off != nil && current.off.tick <= p.on.tick) {
+			current = p
+			continue
+		}
+
+		var wins bool
+		switch policy {
+		case MonoHighest:
+			wins = p.on.message[1] > current.on.message[1]
+		case MonoLowest:
+			wins = p.on.message[1] < current.on.message[1]
+		default:
+			wins = true
+		}
+		if !wins {
+			remove(p)
+			continue
+		}
+
+		if current.on.tick == p.on.tick {
+			remove(current)
+		} else if current.off != nil {
+			current.off.tick = p.on.tick
+		} else {
+			ch, key := current.on.message[0]&0x0F, current.on.message[1]
+			t.events = append(t.events, &MIDIEvent{tick: p.on.tick,
+				message: []uint8{0x80 | ch, key, 0}})
+		}
+		current = p
+	}
+
+	events := t.events[:0]
+	for _, e := range t.events {
+		if !drop[e] {
+			events = append(events, e)
+		}
+	}
+	t.events = events
+	t.sortEvents()
+}
+
+// Retrograde reverses the track in time, so that it plays backward: an
+// event at tick t moves to the last tick of the track minus t, and each
+// note starts where its reversed end falls, keeping its length. Events
+// sharing a tick are reversed too, so applying Retrograde twice restores
+// the track. A note that is never released is taken to end at the last
+// tick and gets a note off.
+func (t *MIDITrack) Retrograde() {
+	last := t.lastTick()
+	notes := make(map[*MIDIEvent]bool)
+	for _, p := range t.notePairs() {
+		notes[p.on] = true
+		if p.off == nil {
+			ch, key := p.on.message[0]&0x0F, p.on.message[1]
+			p.off = &MIDIEvent{tick: last, message: []uint8{0x80 | ch, key, 0}}
+			t.events = append(t.events, p.off)
+		}
+		notes[p.off] = true
+		p.on.tick, p.off.tick = last-p.off.tick, last-p.on.tick
+	}
+	for _, e := range t.events {
+		if !notes[e] && !isEndOfTrack(e.message) {
+			e.tick = last - e.tick
+		}
+	}
+	for i, j := 0, len(t.events)-1; i < j; i, j = i+1, j-1 {
+		t.events[i], t.events[j] = t.events[j], t.events[i]
+	}
+	t.sortEvents()
+}
@@ -0,0 +1,175 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func spans(notes []Note) [][2]int64 {
+	var s [][2]int64
+	for _, n := range notes {
+		s = append(s, [2]int64{n.Start, n.End})
+	}
+	return s
+}
+
+func newArticulationTrack() *MIDITrack {
+	return newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 400, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 1000, message: []uint8{0x80, 62, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 2000, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 2000, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+}
+
+func TestLegato(t *testing.T) {
+	track := newArticulationTrack()
+	track.Legato(120)
+
+	want := [][2]int64{{0, 480}, {480, 960}, {960, 1000}, {1920, 2000}}
+	if got := spans(track.Notes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Legato(120) spans = %v, want %v", got, want)
+	}
+
+	// The extended note off must precede the following note on.
+	if e := track.At(1); e.Tick() != 480 || e.Message()[0] != 0x80 {
+		t.Errorf("event 1 = %d % X, want note off at 480", e.Tick(), e.Message())
+	}
+}
+
+func TestDetach(t *testing.T) {
+	track := newArticulationTrack()
+	track.Detach(60)
+
+	want := [][2]int64{{0, 400}, {480, 900}, {960, 1000}, {1920, 2000}}
+	if got := spans(track.Notes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Detach(60) spans = %v, want %v", got, want)
+	}
+	if e := track.At(track.Len() - 1); !isEndOfTrack(e.Message()) {
+		t.Errorf("end of track must stay last")
+	}
+}
+
+func TestMergeTiedNotes(t *testing.T) {
+	track := newArticulationTrack()
+	if n := track.MergeTiedNotes(60); n != 0 {
+		t.Errorf("MergeTiedNotes(60) = %d, want 0", n)
+	}
+	if n := track.MergeTiedNotes(80); n != 1 {
+		t.Errorf("MergeTiedNotes(80) = %d, want 1", n)
+	}
+	want := [][2]int64{{0, 960}, {960, 1000}, {1920, 2000}}
+	if got := spans(track.Notes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeTiedNotes(80) spans = %v, want %v", got, want)
+	}
+
+	// Undo SplitAtBarLines when the exporter shortened each piece.
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 960, message: []uint8{0x92, 67, 90}},
+		&MIDIEvent{tick: 5000, message: []uint8{0x82, 67, 0}},
+		&MIDIEvent{tick: 5000, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+	track = d.At(0)
+	orig := track.Notes()
+	if n, err := d.SplitAtBarLines(0); err != nil || n != 1 {
+		t.Fatalf("SplitAtBarLines(0) = %d, %v, want 1, nil", n, err)
+	}
+	for i := 0; i < track.Len(); i++ {
+		if e := track.At(i); e.Message()[0] == 0x82 && e.Tick() < 5000 {
+			e.tick -= 10
+		}
+	}
+	if n := track.MergeTiedNotes(10); n != 2 {
+		t.Errorf("MergeTiedNotes(10) = %d, want 2", n)
+	}
+	if got := track.Notes(); !reflect.DeepEqual(got, orig) {
+		t.Errorf("Notes() after merge = %v, want %v", got, orig)
+	}
+}
+
+func TestMonophonic(t *testing.T) {
+	newTrack := func() *MIDITrack {
+		return newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 64, 100}},
+			&MIDIEvent{tick: 240, message: []uint8{0x91, 55, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, 64, 0}},
+			&MIDIEvent{tick: 720, message: []uint8{0x81, 55, 0}},
+			&MIDIEvent{tick: 960, message: []uint8{0x90, 67, 100}},
+			&MIDIEvent{tick: 1200, message: []uint8{0x80, 67, 0}},
+		)
+	}
+
+	cases := []struct {
+		policy MonoPolicy
+		want   []Note
+	}{
+		{MonoHighest, []Note{
+			{Key: 64, Velocity: 100, Start: 0, End: 480},
+			{Key: 67, Velocity: 100, Start: 960, End: 1200},
+		}},
+		{MonoLowest, []Note{
+			{Key: 60, Velocity: 100, Start: 0, End: 240},
+			{Channel: 1, Key: 55, Velocity: 100, Start: 240, End: 720},
+			{Key: 67, Velocity: 100, Start: 960, End: 1200},
+		}},
+		{MonoLast, []Note{
+			{Key: 64, Velocity: 100, Start: 0, End: 240},
+			{Channel: 1, Key: 55, Velocity: 100, Start: 240, End: 720},
+			{Key: 67, Velocity: 100, Start: 960, End: 1200},
+		}},
+	}
+	for _, c := range cases {
+		track := newTrack()
+		track.Monophonic(c.policy)
+		notes := track.Notes()
+		for i := 1; i < len(notes); i++ {
+			if notes[i].Start < notes[i-1].End {
+				t.Errorf("policy %d: notes %v and %v overlap",
+					c.policy, notes[i-1], notes[i])
+			}
+		}
+		if !reflect.DeepEqual(notes, c.want) {
+			t.Errorf("policy %d: Notes() = %v, want %v", c.policy, notes, c.want)
+		}
+	}
+}
+
+func TestRetrograde(t *testing.T) {
+	newTrack := func() *MIDITrack {
+		return newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xC0, 5}},
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 240, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 480, message: []uint8{0x90, 64, 90}},
+			&MIDIEvent{tick: 600, message: []uint8{0xB0, 7, 80}},
+			&MIDIEvent{tick: 960, message: []uint8{0x80, 64, 0}},
+			&MIDIEvent{tick: 1200, message: []uint8{0xFF, 0x2F, 0x00}},
+		)
+	}
+
+	track := newTrack()
+	track.Retrograde()
+	want := []Note{
+		{Key: 64, Velocity: 90, Start: 240, End: 720},
+		{Key: 60, Velocity: 100, Start: 960, End: 1200},
+	}
+	if got := track.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %v, want %v", got, want)
+	}
+
+	track.Retrograde()
+	orig := newTrack()
+	if !track.Equal(orig) {
+		for i := 0; i < track.Len(); i++ {
+			t.Logf("%d % X", track.At(i).Tick(), track.At(i).Message())
+		}
+		t.Error("Retrograde twice didn't restore the track")
+	}
+}
@@ -0,0 +1,213 @@
+package midi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// isTimeSignatureEvent reports whether message is a time signature meta
+// event.
+func isTimeSignatureEvent(message []uint8) bool {
+	return len(message) == 7 && message[0] == 0xFF &&
+		message[1] == 0x58 && message[2] == 0x04
+}
+
+// updateMaps rebuilds the tempo and time signature maps. It must be called
+// whenever events are added, removed or moved.
+func (d *MIDIData) updateMaps() {
+	d.updateTempoMap()
+	d.updateTimeSignatureMap()
+}
+
+// updateTimeSignatureMap rebuilds the time signature map from the time
+// signature meta events found in all tracks.
+func (d *MIDIData) updateTimeSignatureMap() {
+	d.timeSigEvents = []TimeSignature{{
+		Count:      0,
+		BeatPerBar: 4,
+		BeatUnit:   4,
+	}}
+
+	var changes []TimeSignature
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if !isTimeSignatureEvent(e.message) || e.message[4] > 6 {
+				continue
+			}
+			changes = append(changes, TimeSignature{
+				Count:      uint64(e.tick),
+				BeatPerBar: int(e.message[3]),
+				BeatUnit:   1 << e.message[4],
+			})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Count < changes[j].Count
+	})
+
+	for _, c := range changes {
+		if c.BeatPerBar == 0 {
+			continue
+		}
+		tail := len(d.timeSigEvents) - 1
+		if c.Count > d.timeSigEvents[tail].Count {
+			d.timeSigEvents = append(d.timeSigEvents, c)
+		} else {
+			d.timeSigEvents[tail] = c
+		}
+	}
+}
+
+// TimeSignatures returns the time signature map, starting with the time
+// signature in effect at tick 0 (4/4 if the data doesn't specify one).
+func (d *MIDIData) TimeSignatures() []TimeSignature {
+	if d.timeSigEvents == nil {
+		d.updateTimeSignatureMap()
+	}
+	return d.timeSigEvents
+}
+
+// BeatsToTicks converts a length in quarter note beats to ticks using the
+// division, rounding to the nearest tick. It returns 0 for time-code
+// division, which has no beats.
+func (d *MIDIData) BeatsToTicks(beats float64) int64 {
+	if d.Division&0x8000 > 0 {
+		return 0
+	}
+	return int64(math.Floor(beats*float64(d.Division&0x7FFF) + 0.5))
+}
+
+// barLength returns the length of a bar in ticks for ts.
+func (d *MIDIData) barLength(ts TimeSignature) int64 {
+	return int64(ts.BeatPerBar) * int64(d.Division&0x7FFF) * 4 /
+		int64(ts.BeatUnit)
+}
+
+// BarTicks returns the start and end ticks of the given bar. Bars are
+// numbered from 1, and a time signature change is assumed to start a new
+// bar.
+func (d *MIDIData) BarTicks(bar int) (int64, int64, error) {
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return 0, 0, errors.New("bars require metrical division")
+	}
+	if bar < 1 {
+		return 0, 0, fmt.Errorf("bar %d out of range", bar)
+	}
+
+	sigs := d.TimeSignatures()
+	last := d.LastTick()
+	var start int64
+	for i, ts := range sigs {
+		length := d.barLength(ts)
+		if length <= 0 {
+			return 0, 0, errors.New("invalid time signature")
+		}
+		start = int64(ts.Count)
+		if i+1 < len(sigs) {
+			span := int64(sigs[i+1].Count) - start
+			bars := int((span + length - 1) / length)
+			if bar > bars {
+				bar -= bars
+				continue
+			}
+		}
+		start += int64(bar-1) * length
+		if start >= last && !(start == 0 && last == 0) {
+			break
+		}
+		end := start + length
+		if i+1 < len(sigs) && end > int64(sigs[i+1].Count) {
+			end = int64(sigs[i+1].Count)
+		}
+		return start, end, nil
+	}
+
+	return 0, 0, fmt.Errorf("bar %d out of range", bar)
+}
+
+// barLines returns the start ticks of the bars that begin after tick 0
+// and before until. The division must be metrical.
+func (d *MIDIData) barLines(until int64) ([]int64, error) {
+	sigs := d.TimeSignatures()
+	var lines []int64
+	for i, ts := range sigs {
+		length := d.barLength(ts)
+		if length <= 0 {
+			return nil, errors.New("invalid time signature")
+		}
+		end := until
+		if i+1 < len(sigs) && int64(sigs[i+1].Count) < end {
+			end = int64(sigs[i+1].Count)
+		}
+		for tick := int64(ts.Count); tick < end; tick += length {
+			if tick > 0 {
+				lines = append(lines, tick)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// SplitAtBarLines splits every note of the given track that crosses a bar
+// line with SplitAt, as needed for notation where such notes are tied. It
+// returns the number of notes that were split. JoinSplits on the track
+// undoes it exactly.
+func (d *MIDIData) SplitAtBarLines(track int) (int, error) {
+	if track < 0 || track >= len(d.tracks) {
+		return 0, fmt.Errorf("invalid track %d", track)
+	}
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return 0, errors.New("bars require metrical division")
+	}
+	t := d.tracks[track]
+	lines, err := d.barLines(t.lastTick())
+	if err != nil {
+		return 0, err
+	}
+	return t.SplitAt(lines), nil
+}
+
+// SplitAt splits every note that sounds across one of the given ticks,
+// which must be sorted, into notes that end and start again at that tick.
+// It returns the number of notes that were split. The note ons and offs it
+// inserts are marked, so that JoinSplits rejoins exactly these pieces; the
+// marks are kept by copies of the track but not written to files.
+func (t *MIDITrack) SplitAt(ticks []int64) int {
+	var split int
+	for _, p := range t.notePairs() {
+		if p.off == nil {
+			continue
+		}
+		i := sort.Search(len(ticks), func(i int) bool {
+			return ticks[i] > p.on.tick
+		})
+		if i == len(ticks) || ticks[i] >= p.off.tick {
+			continue
+		}
+		split++
+		ch, key := p.on.message[0]&0x0F, p.on.message[1]
+		for ; i < len(ticks) && ticks[i] < p.off.tick; i++ {
+			if i > 0 && ticks[i] == ticks[i-1] {
+				continue
+			}
+			t.events = append(t.events,
+				&MIDIEvent{tick: ticks[i], message: []uint8{0x80 | ch, key, 0}, tie: true},
+				&MIDIEvent{tick: ticks[i], message: []uint8{p.on.message[0], key, p.on.message[2]}, tie: true})
+		}
+	}
+	t.sortEvents()
+	return split
+}
+
+// Measure returns the events of the given bar as new MIDI data starting
+// at tick 0, with the tempo and time signature in effect at the start of
+// the bar. Bars are numbered from 1.
+func (d *MIDIData) Measure(bar int) (*MIDIData, error) {
+	start, end, err := d.BarTicks(bar)
+	if err != nil {
+		return nil, err
+	}
+	return d.Cut(start, end), nil
+}
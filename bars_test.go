@@ -0,0 +1,164 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestData(division int, tracks ...*MIDITrack) *MIDIData {
+	d := &MIDIData{
+		Format:   1,
+		Division: division,
+	}
+	for _, t := range tracks {
+		d.Append(t)
+	}
+	d.updateMaps()
+	return d
+}
+
+func TestBarTicks(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x58, 0x04, 4, 2, 24, 8}},
+			&MIDIEvent{tick: 3840, message: []uint8{0xFF, 0x58, 0x04, 3, 2, 24, 8}},
+			&MIDIEvent{tick: 9600, message: []uint8{0xFF, 0x2F, 0x00}},
+		))
+
+	cases := []struct {
+		bar        int
+		start, end int64
+	}{
+		{1, 0, 1920},
+		{2, 1920, 3840},
+		{3, 3840, 5280},
+		{5, 6720, 8160},
+	}
+	for _, c := range cases {
+		start, end, err := d.BarTicks(c.bar)
+		if err != nil {
+			t.Errorf("BarTicks(%d): %v", c.bar, err)
+			continue
+		}
+		if start != c.start || end != c.end {
+			t.Errorf("BarTicks(%d) = %d, %d; want %d, %d",
+				c.bar, start, end, c.start, c.end)
+		}
+	}
+
+	for _, bar := range []int{0, 8} {
+		if _, _, err := d.BarTicks(bar); err == nil {
+			t.Errorf("BarTicks(%d) should fail", bar)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20}},
+			&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40}},
+			&MIDIEvent{tick: 3840, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 2400, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 2400, message: []uint8{0x90, 62, 100}},
+			&MIDIEvent{tick: 2880, message: []uint8{0x80, 62, 0}},
+			&MIDIEvent{tick: 3360, message: []uint8{0x90, 64, 100}},
+			&MIDIEvent{tick: 3840, message: []uint8{0x80, 64, 0}},
+		))
+
+	m, err := d.Measure(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", m.Len())
+	}
+
+	tempo := m.At(0).At(0)
+	if tempo.Tick() != 0 || !isTempoEvent(tempo.Message()) ||
+		tempo.Message()[3] != 0x0F {
+		t.Errorf("expected 60 bpm tempo at tick 0, got %v", tempo)
+	}
+
+	notes := m.At(1)
+	want := []struct {
+		tick int64
+		msg  []uint8
+	}{
+		{480, []uint8{0x90, 62, 100}},
+		{960, []uint8{0x80, 62, 0}},
+		{1440, []uint8{0x90, 64, 100}},
+		{1920, []uint8{0x80, 64, 0}},
+		{1920, []uint8{0xFF, 0x2F, 0x00}},
+	}
+	if notes.Len() != len(want) {
+		t.Fatalf("got %d events, want %d", notes.Len(), len(want))
+	}
+	for i, w := range want {
+		e := notes.At(i)
+		if e.Tick() != w.tick || string(e.Message()) != string(w.msg) {
+			t.Errorf("event %d = %d % X, want %d % X",
+				i, e.Tick(), e.Message(), w.tick, w.msg)
+		}
+	}
+
+	if _, err := d.Measure(3); err == nil {
+		t.Errorf("Measure(3) should fail")
+	}
+}
+
+func TestSplitAtBarLines(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 62, 80}},
+		&MIDIEvent{tick: 480, message: []uint8{0x81, 62, 0}},
+		&MIDIEvent{tick: 1440, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 1440, message: []uint8{0x90, 64, 90}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x90, 64, 70}},
+		&MIDIEvent{tick: 2400, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 4200, message: []uint8{0x80, 60, 0}},
+	))
+	track := d.At(0)
+	orig := track.clone()
+
+	n, err := d.SplitAtBarLines(0)
+	if err != nil || n != 1 {
+		t.Fatalf("SplitAtBarLines(0) = %d, %v, want 1, nil", n, err)
+	}
+	want := []Note{
+		{Channel: 1, Key: 62, Velocity: 80, Start: 0, End: 480},
+		{Channel: 0, Key: 60, Velocity: 100, Start: 1440, End: 1920},
+		{Channel: 0, Key: 64, Velocity: 90, Start: 1440, End: 1920},
+		{Channel: 0, Key: 64, Velocity: 70, Start: 1920, End: 2400},
+		{Channel: 0, Key: 60, Velocity: 100, Start: 1920, End: 3840},
+		{Channel: 0, Key: 60, Velocity: 100, Start: 3840, End: 4200},
+	}
+	if got := track.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() after split = %v, want %v", got, want)
+	}
+
+	// Only the split pieces are joined, not the re-struck key 64.
+	if n := track.JoinSplits(); n != 2 {
+		t.Errorf("JoinSplits() = %d, want 2", n)
+	}
+	if track.Len() != orig.Len() {
+		t.Fatalf("Len() after join = %d, want %d", track.Len(), orig.Len())
+	}
+	for i := 0; i < orig.Len(); i++ {
+		e, w := track.At(i), orig.At(i)
+		if e.Tick() != w.Tick() || string(e.Message()) != string(w.Message()) {
+			t.Errorf("event %d after join = %d % X, want %d % X",
+				i, e.Tick(), e.Message(), w.Tick(), w.Message())
+		}
+	}
+	if n := track.JoinSplits(); n != 0 {
+		t.Errorf("second JoinSplits() = %d, want 0", n)
+	}
+
+	if _, err := d.SplitAtBarLines(1); err == nil {
+		t.Error("SplitAtBarLines(1) succeeded for a missing track")
+	}
+}
@@ -0,0 +1,417 @@
+package midi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProgramEvent is a program change at an absolute tick.
+type ProgramEvent struct {
+	Tick    int64
+	Channel int
+	Program int
+}
+
+// ProgramChanges returns every program change in the track, in order.
+func (t *MIDITrack) ProgramChanges() []ProgramEvent {
+	var changes []ProgramEvent
+	for _, e := range t.events {
+		msg, err := ParseMessage(e.message)
+		if err != nil {
+			continue
+		}
+		if pc, ok := msg.(ProgramChange); ok {
+			changes = append(changes, ProgramEvent{
+				Tick:    e.tick,
+				Channel: pc.Channel,
+				Program: pc.Program,
+			})
+		}
+	}
+	return changes
+}
+
+// Channels returns the sorted set of channels used by the channel
+// messages of the track.
+func (t *MIDITrack) Channels() []int {
+	var used [16]bool
+	for _, e := range t.events {
+		if ch, ok := channelOf(e.message); ok {
+			used[ch] = true
+		}
+	}
+
+	var channels []int
+	for ch, ok := range used {
+		if ok {
+			channels = append(channels, ch)
+		}
+	}
+	return channels
+}
+
+// ForceChannel moves every channel message of the track to channel ch,
+// collapsing a multi-channel arrangement onto one channel, e.g. for a
+// monotimbral synth. Events are stored with their status byte, so running
+// status needs no special care. It returns an error if ch isn't in 0..15.
+func (t *MIDITrack) ForceChannel(ch int) error {
+	if ch < 0 || ch > 15 {
+		return fmt.Errorf("invalid channel %d", ch)
+	}
+	for _, e := range t.events {
+		if _, ok := channelOf(e.message); ok {
+			e.message[0] = e.message[0]&0xF0 | uint8(ch)
+		}
+	}
+	return nil
+}
+
+// ForceChannel moves every channel message of every track to channel ch.
+// See (*MIDITrack).ForceChannel.
+func (d *MIDIData) ForceChannel(ch int) error {
+	if ch < 0 || ch > 15 {
+		return fmt.Errorf("invalid channel %d", ch)
+	}
+	for _, t := range d.tracks {
+		t.ForceChannel(ch)
+	}
+	return nil
+}
+
+// ChannelUsage returns the number of channel messages on each channel
+// across all tracks.
+func (d *MIDIData) ChannelUsage() [16]int {
+	var usage [16]int
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if ch, ok := channelOf(e.message); ok {
+				usage[ch]++
+			}
+		}
+	}
+	return usage
+}
+
+// IsDrumsOnly reports whether every channel message of the data is on
+// PercussionChannel, e.g. to sort a corpus into drum loops and the rest.
+// It also returns the number of channel messages on other channels, the
+// reason for a false result. Data without channel messages isn't drums
+// only.
+func (d *MIDIData) IsDrumsOnly() (bool, int) {
+	usage := d.ChannelUsage()
+	other := 0
+	for ch, n := range usage {
+		if ch != PercussionChannel {
+			other += n
+		}
+	}
+	return other == 0 && usage[PercussionChannel] > 0, other
+}
+
+// ChannelConflicts reports the channels that receive channel messages
+// from more than one track, mapping each such channel to the sorted track
+// indices that use it. Such tracks may fight over controllers and
+// programs when played on a single device.
+func (d *MIDIData) ChannelConflicts() map[int][]int {
+	users := make(map[int][]int)
+	for i, t := range d.tracks {
+		for _, ch := range t.Channels() {
+			users[ch] = append(users[ch], i)
+		}
+	}
+
+	conflicts := make(map[int][]int)
+	for ch, tracks := range users {
+		if len(tracks) > 1 {
+			conflicts[ch] = tracks
+		}
+	}
+	return conflicts
+}
+
+// Instrument is a distinct program selected on a channel.
+type Instrument struct {
+	Channel int
+	Bank    int // 14-bit bank from bank select (CC 0 and 32)
+	Program int
+	Name    string // General MIDI name; empty for non-GM banks
+}
+
+// InstrumentsUsed returns each distinct (channel, bank, program)
+// selected across all tracks, in the order first selected. Bank select
+// controllers are tracked per channel and apply to later program changes.
+// Instruments on the percussion channel are named "Percussion".
+func (d *MIDIData) InstrumentsUsed() []Instrument {
+	var banks [16]int
+	seen := make(map[Instrument]bool)
+	var instruments []Instrument
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		msg, err := ParseMessage(e.message)
+		if err != nil {
+			return true
+		}
+		switch m := msg.(type) {
+		case ControlChange:
+			switch m.Controller {
+			case 0:
+				banks[m.Channel] = m.Value<<7 | banks[m.Channel]&0x7F
+			case 32:
+				banks[m.Channel] = banks[m.Channel]&^0x7F | m.Value
+			}
+		case ProgramChange:
+			inst := Instrument{
+				Channel: m.Channel,
+				Bank:    banks[m.Channel],
+				Program: m.Program,
+			}
+			if seen[inst] {
+				return true
+			}
+			seen[inst] = true
+			switch {
+			case inst.Channel == PercussionChannel:
+				inst.Name = "Percussion"
+			case inst.Bank == 0:
+				inst.Name = GMProgramNames[inst.Program]
+			}
+			instruments = append(instruments, inst)
+		}
+		return true
+	})
+	return instruments
+}
+
+// AutoAllocateChannels gives every track with melodic channel messages a
+// channel of its own, so that the tracks can be merged into format 0
+// without fighting over channels. PercussionChannel is reserved for drums:
+// events on it are left as they are, and tracks that only use it map to
+// it. Other tracks keep their first melodic channel if no earlier track
+// claimed it, or get the lowest free channel, and all their melodic
+// events are moved to it. The returned mapping gives the channel of each
+// track with channel messages. If more than 15 tracks need a melodic
+// channel, an error is returned and nothing is changed.
+func (d *MIDIData) AutoAllocateChannels() (map[int]int, error) {
+	mapping := make(map[int]int)
+	var melodic []int
+	first := make(map[int]int)
+	for i, t := range d.tracks {
+		for _, ch := range t.Channels() {
+			if ch != PercussionChannel {
+				melodic = append(melodic, i)
+				first[i] = ch
+				break
+			}
+		}
+		if _, ok := first[i]; !ok && len(t.Channels()) > 0 {
+			mapping[i] = PercussionChannel
+		}
+	}
+	if len(melodic) > 15 {
+		return nil, fmt.Errorf("%d tracks need a melodic channel, only 15 exist",
+			len(melodic))
+	}
+
+	var used [16]bool
+	used[PercussionChannel] = true
+	for _, i := range melodic {
+		if !used[first[i]] {
+			mapping[i] = first[i]
+			used[first[i]] = true
+		}
+	}
+	for _, i := range melodic {
+		if _, ok := mapping[i]; ok {
+			continue
+		}
+		for ch := range used {
+			if !used[ch] {
+				mapping[i] = ch
+				used[ch] = true
+				break
+			}
+		}
+	}
+
+	for _, i := range melodic {
+		for _, e := range d.tracks[i].events {
+			if ch, ok := channelOf(e.message); ok && ch != PercussionChannel {
+				e.message[0] = e.message[0]&0xF0 | uint8(mapping[i])
+			}
+		}
+	}
+	return mapping, nil
+}
+
+// drumRoles maps the keys of the General MIDI percussion map that make up
+// most drum parts to their role in the kit: kick, snare, hi-hat and
+// cymbal.
+var drumRoles = map[int]int{
+	35: 0, 36: 0,
+	37: 1, 38: 1, 39: 1, 40: 1,
+	42: 2, 44: 2, 46: 2,
+	49: 3, 51: 3, 52: 3, 53: 3, 55: 3, 57: 3,
+}
+
+// Issues with at least this confidence are repaired by FixDrumChannel.
+const drumFixConfidence = 0.8
+
+// nameMentions reports whether the lower case name contains any of words.
+func nameMentions(name string, words ...string) bool {
+	for _, w := range words {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// drumIssue is an issue found by DetectDrumMisassignment and the channel
+// FixDrumChannel would move it to.
+type drumIssue struct {
+	Issue
+	target int
+}
+
+// drumIssues implements DetectDrumMisassignment.
+func (d *MIDIData) drumIssues() []drumIssue {
+	var issues []drumIssue
+	for i, t := range d.tracks {
+		name := strings.ToLower(t.Name)
+		drumName := nameMentions(name, "drum", "perc", "kit")
+		melodicName := !drumName && nameMentions(name, "bass", "piano",
+			"guitar", "lead", "melody", "string", "synth", "organ", "vocal",
+			"pad", "brass")
+
+		var notes [16][]Note
+		for _, n := range t.Notes() {
+			notes[n.Channel] = append(notes[n.Channel], n)
+		}
+		var bends [16]bool
+		for _, e := range t.events {
+			if ch, ok := channelOf(e.message); ok && e.message[0]&0xF0 == 0xE0 {
+				bends[ch] = true
+			}
+		}
+
+		for ch, ns := range notes {
+			if len(ns) == 0 {
+				continue
+			}
+			var outside, together int
+			var roles [4]bool
+			for j, n := range ns {
+				if n.Key < 27 || n.Key > 87 {
+					outside++
+				}
+				if role, ok := drumRoles[n.Key]; ok {
+					roles[role] = true
+				}
+				for k := j - 1; k >= 0 && ns[k].Start == n.Start; k-- {
+					if ns[k].Key != n.Key {
+						together++
+						break
+					}
+				}
+				for k := j + 1; k < len(ns) && ns[k].Start == n.Start; k++ {
+					if ns[k].Key != n.Key {
+						together++
+						break
+					}
+				}
+			}
+			issue := drumIssue{Issue: Issue{Track: i, Channel: ch, Tick: ns[0].Start}}
+
+			if ch == PercussionChannel {
+				issue.Confidence = float64(outside) / float64(len(ns))
+				if bends[ch] && issue.Confidence < 0.8 {
+					issue.Confidence = 0.8
+				}
+				issue.Message = "melodic notes on percussion channel"
+				issue.target = -1
+			} else {
+				if bends[ch] || outside > 0 {
+					continue
+				}
+				var used int
+				for _, ok := range roles {
+					if ok {
+						used++
+					}
+				}
+				issue.Confidence = (float64(together)/float64(len(ns)) +
+					float64(used)/4) / 2
+				switch {
+				case drumName:
+					issue.Confidence += 0.25
+				case melodicName:
+					issue.Confidence -= 0.5
+				}
+				if issue.Confidence > 1 {
+					issue.Confidence = 1
+				}
+				issue.Message = "drum notes on melodic channel"
+				issue.target = PercussionChannel
+			}
+			if issue.Confidence >= 0.5 {
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues
+}
+
+// DetectDrumMisassignment looks for drum parts on melodic channels and
+// melodic parts on PercussionChannel, which General MIDI devices play with
+// the wrong sounds. Each track and channel with notes is judged on its
+// own. Notes on PercussionChannel are considered melodic in proportion to
+// how many fall outside the extended percussion map (keys 27 to 87), and
+// with confidence at least 0.8 if the channel is pitch bent. Notes on
+// other channels are considered drums only if they are never pitch bent
+// and all fall inside that map. As kick and snare keys are ordinary bass
+// pitches, the confidence then rests on how a kit is played: it is the
+// average of the share of notes struck together with a note of another
+// key and the share of the kit's kick, snare, hi-hat and cymbal roles
+// used, raised by 0.25 if the track name mentions drums, percussion or a
+// kit, and lowered by 0.5 if it names a melodic instrument such as bass
+// or piano. Issues with confidence below 0.5 aren't reported.
+func (d *MIDIData) DetectDrumMisassignment() []Issue {
+	var issues []Issue
+	for _, issue := range d.drumIssues() {
+		issues = append(issues, issue.Issue)
+	}
+	return issues
+}
+
+// FixDrumChannel repairs the issues found by DetectDrumMisassignment with
+// confidence of at least 0.8, and returns the repaired issues. The
+// channel messages of a drum part are moved to PercussionChannel, and
+// those of a melodic part on PercussionChannel to the lowest channel not
+// used anywhere in the data; such a part is left alone if every channel
+// is in use.
+func (d *MIDIData) FixDrumChannel() []Issue {
+	var fixed []Issue
+	usage := d.ChannelUsage()
+	for _, issue := range d.drumIssues() {
+		if issue.Confidence < drumFixConfidence {
+			continue
+		}
+		target := issue.target
+		for ch := 0; target < 0 && ch < 16; ch++ {
+			if ch != PercussionChannel && usage[ch] == 0 {
+				target = ch
+			}
+		}
+		if target < 0 {
+			continue
+		}
+		for _, e := range d.tracks[issue.Track].events {
+			if ch, ok := channelOf(e.message); ok && ch == issue.Channel {
+				e.message[0] = e.message[0]&0xF0 | uint8(target)
+				usage[ch]--
+				usage[target]++
+			}
+		}
+		fixed = append(fixed, issue.Issue)
+	}
+	return fixed
+}
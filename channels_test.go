@@ -0,0 +1,261 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProgramChanges(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x03, 0x01, 'a'}},
+		&MIDIEvent{tick: 0, message: []uint8{0xC0, 0}},
+		&MIDIEvent{tick: 0, message: []uint8{0xC1, 40}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0xC0, 24}},
+	)
+
+	want := []ProgramEvent{
+		{Tick: 0, Channel: 0, Program: 0},
+		{Tick: 0, Channel: 1, Program: 40},
+		{Tick: 480, Channel: 0, Program: 24},
+	}
+	if got := track.ProgramChanges(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ProgramChanges() = %v, want %v", got, want)
+	}
+}
+
+func TestChannels(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x20, 0x01, 0x05}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x92, 60, 100}},
+		&MIDIEvent{tick: 10, message: []uint8{0x82, 60, 0}},
+	)
+	if got := track.Channels(); !reflect.DeepEqual(got, []int{2, 9}) {
+		t.Errorf("Channels() = %v, want [2 9]", got)
+	}
+	if got := newTestTrack().Channels(); got != nil {
+		t.Errorf("Channels() of empty track = %v, want nil", got)
+	}
+
+	d := newTestData(480, track, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xC2, 1}},
+	))
+	var want [16]int
+	want[2] = 3
+	want[9] = 1
+	if got := d.ChannelUsage(); got != want {
+		t.Errorf("ChannelUsage() = %v, want %v", got, want)
+	}
+}
+
+func TestChannelConflicts(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(&MIDIEvent{message: tempoMessage(120)}),
+		newTestTrack(&MIDIEvent{message: []uint8{0x90, 60, 100}}),
+		newTestTrack(&MIDIEvent{message: []uint8{0x91, 60, 100}}),
+		newTestTrack(
+			&MIDIEvent{message: []uint8{0xB0, 7, 100}},
+			&MIDIEvent{message: []uint8{0x99, 36, 100}},
+		),
+		newTestTrack(&MIDIEvent{message: []uint8{0xC0, 5}}),
+	)
+
+	want := map[int][]int{0: {1, 3, 4}}
+	if got := d.ChannelConflicts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChannelConflicts() = %v, want %v", got, want)
+	}
+}
+
+func TestInstrumentsUsed(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xC0, 0}},
+			&MIDIEvent{tick: 0, message: []uint8{0xC9, 0}},
+			&MIDIEvent{tick: 480, message: []uint8{0xC0, 0}},
+			&MIDIEvent{tick: 960, message: []uint8{0xB0, 0, 1}},
+			&MIDIEvent{tick: 960, message: []uint8{0xB0, 32, 2}},
+			&MIDIEvent{tick: 960, message: []uint8{0xC0, 0}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 240, message: []uint8{0xC1, 40}},
+		),
+	)
+
+	want := []Instrument{
+		{Channel: 0, Program: 0, Name: "Acoustic Grand Piano"},
+		{Channel: 9, Program: 0, Name: "Percussion"},
+		{Channel: 1, Program: 40, Name: "Violin"},
+		{Channel: 0, Bank: 130, Program: 0},
+	}
+	if got := d.InstrumentsUsed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("InstrumentsUsed() = %v, want %v", got, want)
+	}
+}
+
+func TestAutoAllocateChannels(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(&MIDIEvent{message: tempoMessage(120)}),
+		newTestTrack(&MIDIEvent{message: []uint8{0x90, 60, 100}}),
+		newTestTrack(
+			&MIDIEvent{message: []uint8{0xC0, 33}},
+			&MIDIEvent{message: []uint8{0x90, 40, 100}},
+		),
+		newTestTrack(&MIDIEvent{message: []uint8{0x99, 36, 100}}),
+		newTestTrack(
+			&MIDIEvent{message: []uint8{0x91, 72, 100}},
+			&MIDIEvent{message: []uint8{0x99, 42, 100}},
+		),
+	)
+
+	mapping, err := d.AutoAllocateChannels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]int{1: 0, 2: 2, 3: 9, 4: 1}
+	if !reflect.DeepEqual(mapping, want) {
+		t.Errorf("AutoAllocateChannels() = %v, want %v", mapping, want)
+	}
+	if got := d.At(2).Channels(); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("track 2 channels = %v, want [2]", got)
+	}
+	if got := d.At(4).Channels(); !reflect.DeepEqual(got, []int{1, 9}) {
+		t.Errorf("track 4 channels = %v, want [1 9]", got)
+	}
+
+	var tracks []*MIDITrack
+	for i := 0; i < 16; i++ {
+		tracks = append(tracks, newTestTrack(
+			&MIDIEvent{message: []uint8{0x90, 60, 100}}))
+	}
+	d = newTestData(480, tracks...)
+	if _, err := d.AutoAllocateChannels(); err == nil {
+		t.Error("AutoAllocateChannels() succeeded for 16 melodic tracks")
+	}
+	if got := d.At(15).Channels(); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("failed allocation changed channels to %v", got)
+	}
+}
+
+func TestIsDrumsOnly(t *testing.T) {
+	drums := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x03, 0x04, 'K', 'i', 't', 's'}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 60, message: []uint8{0x89, 36, 0}},
+	)
+	if ok, other := newTestData(480, drums).IsDrumsOnly(); !ok || other != 0 {
+		t.Errorf("IsDrumsOnly() = %v, %d, want true, 0", ok, other)
+	}
+
+	bass := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 36, 100}},
+		&MIDIEvent{tick: 60, message: []uint8{0x81, 36, 0}},
+	)
+	if ok, other := newTestData(480, drums, bass).IsDrumsOnly(); ok || other != 2 {
+		t.Errorf("IsDrumsOnly() = %v, %d, want false, 2", ok, other)
+	}
+	if ok, _ := newTestData(480).IsDrumsOnly(); ok {
+		t.Error("IsDrumsOnly() of empty data should be false")
+	}
+}
+
+func TestForceChannel(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xC2, 40}},
+			&MIDIEvent{tick: 0, message: []uint8{0x92, 60, 100}},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x20, 0x01, 0x02}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xB5, 7, 100}},
+			&MIDIEvent{tick: 0, message: []uint8{0xF0, 0x03, 0x7E, 0x7F, 0xF7}},
+		))
+
+	if err := d.ForceChannel(16); err == nil {
+		t.Error("ForceChannel(16) should fail")
+	}
+	if err := d.ForceChannel(3); err != nil {
+		t.Fatal(err)
+	}
+	if usage := d.ChannelUsage(); usage[3] != 3 {
+		t.Errorf("ChannelUsage() = %v, want 3 messages on channel 3", usage)
+	}
+	if got := d.At(1).At(1).Message()[0]; got != 0xF0 {
+		t.Errorf("sysex status = %X, want F0", got)
+	}
+}
+
+func TestDrumMisassignment(t *testing.T) {
+	drums := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 36, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 42, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 49, 100}},
+		&MIDIEvent{tick: 60, message: []uint8{0x80, 36, 0}},
+		&MIDIEvent{tick: 60, message: []uint8{0x80, 42, 0}},
+		&MIDIEvent{tick: 60, message: []uint8{0x80, 49, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 38, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 42, 100}},
+		&MIDIEvent{tick: 540, message: []uint8{0x80, 38, 0}},
+		&MIDIEvent{tick: 540, message: []uint8{0x80, 42, 0}},
+		&MIDIEvent{tick: 540, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	drums.Name = "Drums"
+	lead := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 72, 100}},
+		&MIDIEvent{tick: 240, message: []uint8{0xE9, 0x00, 0x50}},
+		&MIDIEvent{tick: 480, message: []uint8{0x89, 72, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x99, 74, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x89, 74, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	bass := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 40, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x81, 40, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x91, 43, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x81, 43, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x91, 45, 100}},
+		&MIDIEvent{tick: 1440, message: []uint8{0x81, 45, 0}},
+		&MIDIEvent{tick: 1440, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	d := newTestData(480, drums, lead, bass)
+
+	want := []Issue{
+		{Track: 0, Channel: 0, Tick: 0, Message: "drum notes on melodic channel", Confidence: 1},
+		{Track: 1, Channel: 9, Tick: 0, Message: "melodic notes on percussion channel", Confidence: 0.8},
+	}
+	if got := d.DetectDrumMisassignment(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectDrumMisassignment() = %v, want %v", got, want)
+	}
+	if got := d.FixDrumChannel(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FixDrumChannel() = %v, want %v", got, want)
+	}
+	for i, want := range [][]int{{PercussionChannel}, {0}, {1}} {
+		if got := d.At(i).Channels(); !reflect.DeepEqual(got, want) {
+			t.Errorf("track %d channels = %v, want %v", i, got, want)
+		}
+	}
+	if got := d.DetectDrumMisassignment(); got != nil {
+		t.Errorf("DetectDrumMisassignment() after fix = %v", got)
+	}
+	if got, want := want[1].String(),
+		"track 1, channel 9, tick 0: melodic notes on percussion channel (confidence 0.80)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// A staccato bass line on kick and snare keys isn't a drum part.
+	staccato := newTestTrack()
+	for i, key := range []uint8{35, 36, 38, 40, 36, 35, 40, 38} {
+		tick := int64(i) * 240
+		staccato.Append(&MIDIEvent{tick: tick, message: []uint8{0x91, key, 100}})
+		staccato.Append(&MIDIEvent{tick: tick + 60, message: []uint8{0x81, key, 0}})
+	}
+	staccato.Name = "Bass"
+	d = newTestData(480, staccato)
+	if got := d.DetectDrumMisassignment(); got != nil {
+		t.Errorf("DetectDrumMisassignment() for a bass line = %v", got)
+	}
+	staccato.Name = ""
+	if got := d.FixDrumChannel(); got != nil {
+		t.Errorf("FixDrumChannel() for an unnamed bass line = %v", got)
+	}
+}
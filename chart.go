@@ -0,0 +1,62 @@
+package midi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ChartNote is a note of a rhythm game chart.
+type ChartNote struct {
+	Lane     int
+	Start    float64 // seconds
+	End      float64 // seconds
+	Velocity int
+}
+
+// LaneMap assigns note keys to the lanes of a chart.
+type LaneMap map[int]int
+
+// DefaultDrumLanes maps General MIDI drum keys to five lanes: kick (0),
+// snare (1), hi-hat (2), toms (3) and cymbals (4).
+var DefaultDrumLanes = LaneMap{
+	35: 0, 36: 0,
+	37: 1, 38: 1, 39: 1, 40: 1,
+	42: 2, 44: 2, 46: 2,
+	41: 3, 43: 3, 45: 3, 47: 3, 48: 3, 50: 3,
+	49: 4, 51: 4, 52: 4, 53: 4, 55: 4, 57: 4, 59: 4,
+}
+
+// ToChart turns the notes of the given track into chart notes, placing
+// each in the lane its key maps to in lanes and timing it in seconds
+// through the tempo map. Notes with unmapped keys are skipped. If lanes
+// is nil, DefaultDrumLanes is used. The result is ordered by start time,
+// then lane.
+func (d *MIDIData) ToChart(track int, lanes LaneMap) ([]ChartNote, error) {
+	if track < 0 || track >= len(d.tracks) {
+		return nil, fmt.Errorf("invalid track %d", track)
+	}
+	if lanes == nil {
+		lanes = DefaultDrumLanes
+	}
+
+	var chart []ChartNote
+	for _, n := range d.tracks[track].Notes() {
+		lane, ok := lanes[n.Key]
+		if !ok {
+			continue
+		}
+		chart = append(chart, ChartNote{
+			Lane:     lane,
+			Start:    d.TickToSeconds(n.Start),
+			End:      d.TickToSeconds(n.End),
+			Velocity: n.Velocity,
+		})
+	}
+	sort.SliceStable(chart, func(i, j int) bool {
+		if chart[i].Start != chart[j].Start {
+			return chart[i].Start < chart[j].Start
+		}
+		return chart[i].Lane < chart[j].Lane
+	})
+	return chart, nil
+}
@@ -0,0 +1,37 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToChart(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 42, 80}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 81, 100}}, // triangle
+		&MIDIEvent{tick: 96, message: []uint8{0x89, 42, 0}},
+		&MIDIEvent{tick: 96, message: []uint8{0x89, 36, 0}},
+		&MIDIEvent{tick: 96, message: []uint8{0x89, 81, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x99, 38, 110}},
+		&MIDIEvent{tick: 960, message: []uint8{0x89, 38, 0}},
+	))
+
+	chart, err := d.ToChart(0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ChartNote{
+		{Lane: 0, Start: 0, End: 0.1, Velocity: 100},
+		{Lane: 2, Start: 0, End: 0.1, Velocity: 80},
+		{Lane: 1, Start: 0.5, End: 1, Velocity: 110},
+	}
+	if !reflect.DeepEqual(chart, want) {
+		t.Errorf("ToChart(0, nil) = %v, want %v", chart, want)
+	}
+
+	chart, err = d.ToChart(0, LaneMap{81: 7})
+	if err != nil || len(chart) != 1 || chart[0].Lane != 7 {
+		t.Errorf("ToChart with custom lanes = %v, %v", chart, err)
+	}
+}
@@ -0,0 +1,163 @@
+package midi
+
+import (
+	"sort"
+)
+
+// isParameterController reports whether controller is part of an RPN or
+// NRPN sequence, where repeating a value is meaningful.
+func isParameterController(controller uint8) bool {
+	switch controller {
+	case 6, 38, 96, 97, 98, 99, 100, 101:
+		return true
+	}
+	return false
+}
+
+// DedupeControllers removes control changes that repeat the value of the
+// previous control change for the same channel and controller, keeping
+// the first, and returns the number of events removed. With a threshold
+// greater than 0 it also thins smooth sweeps by dropping values within
+// threshold of the last kept one, so the final value of a sweep may be
+// off by at most threshold. Data entry and parameter number controllers
+// are never removed.
+func (t *MIDITrack) DedupeControllers(threshold int) int {
+	last := make(map[[2]uint8]int)
+	events := t.events[:0]
+	removed := 0
+	for _, e := range t.events {
+		msg := e.message
+		if len(msg) == 3 && msg[0]&0xF0 == 0xB0 && msg[1] < 120 &&
+			!isParameterController(msg[1]) {
+			k := [2]uint8{msg[0] & 0x0F, msg[1]}
+			v := int(msg[2])
+			if prev, ok := last[k]; ok && v-prev <= threshold &&
+				prev-v <= threshold {
+				removed++
+				continue
+			}
+			last[k] = v
+		}
+		events = append(events, e)
+	}
+	t.events = events
+	return removed
+}
+
+// SustainRegion is a span in which the sustain pedal is held.
+type SustainRegion struct {
+	StartTick int64
+	EndTick   int64
+}
+
+// SustainRegions returns the spans in which the sustain pedal (CC 64) of
+// the given channel is down, i.e. at 64 or above. A pedal still held at
+// the end of the track ends at its last tick.
+func (t *MIDITrack) SustainRegions(channel int) []SustainRegion {
+	var regions []SustainRegion
+	down := false
+	for _, e := range t.events {
+		msg := e.message
+		if len(msg) != 3 || msg[0] != 0xB0|uint8(channel&0x0F) || msg[1] != 64 {
+			continue
+		}
+		switch {
+		case msg[2] >= 64 && !down:
+			regions = append(regions, SustainRegion{StartTick: e.tick})
+			down = true
+		case msg[2] < 64 && down:
+			regions[len(regions)-1].EndTick = e.tick
+			down = false
+		}
+	}
+	if down {
+		regions[len(regions)-1].EndTick = t.lastTick()
+	}
+	return regions
+}
+
+// ApplySustain bakes the sustain pedal of the given channel into the note
+// lengths: a note released while the pedal is down is extended to where
+// the pedal comes up, or to the next note on of the same key if that is
+// earlier, and the CC 64 events of the channel are then removed. This is
+// lossy; the original note offs and pedal events can't be recovered.
+func (t *MIDITrack) ApplySustain(channel int) {
+	regions := t.SustainRegions(channel)
+	if len(regions) == 0 {
+		return
+	}
+
+	pairs := t.notePairs()
+	nextOn := make(map[*MIDIEvent]int64)
+	lastOn := make(map[[2]int]*MIDIEvent)
+	for i := len(pairs) - 1; i >= 0; i-- {
+		p := pairs[i]
+		k := [2]int{int(p.on.message[0] & 0x0F), int(p.on.message[1])}
+		if next, ok := lastOn[k]; ok {
+			nextOn[p.on] = next.tick
+		}
+		lastOn[k] = p.on
+	}
+
+	for _, p := range pairs {
+		if p.off == nil || int(p.on.message[0]&0x0F) != channel&0x0F {
+			continue
+		}
+		i := sort.Search(len(regions), func(i int) bool {
+			return regions[i].EndTick > p.off.tick
+		})
+		if i == len(regions) || regions[i].StartTick > p.off.tick {
+			continue
+		}
+		end := regions[i].EndTick
+		if next, ok := nextOn[p.on]; ok && next < end && next >= p.off.tick {
+			end = next
+		}
+		p.off.tick = end
+	}
+
+	events := t.events[:0]
+	for _, e := range t.events {
+		msg := e.message
+		if len(msg) == 3 && msg[0] == 0xB0|uint8(channel&0x0F) && msg[1] == 64 {
+			continue
+		}
+		events = append(events, e)
+	}
+	t.events = events
+	t.sortEvents()
+}
+
+// BakeVolumeIntoVelocity renders the channel volume (CC 7) and expression
+// (CC 11) of the given channel into its note on velocities, for
+// synthesizers that ignore those controllers: each velocity is scaled by
+// volume*expression/16129 as in effect at the note on, and the CC 7 and
+// CC 11 events of the channel are then removed. The channel starts at the
+// General MIDI defaults of volume 100 and expression 127. This is lossy:
+// velocities are rounded and clamped to 1..127, and volume changes while
+// a note sounds are lost.
+func (t *MIDITrack) BakeVolumeIntoVelocity(channel int) {
+	ch := uint8(channel & 0x0F)
+	volume, expression := 100, 127
+	events := t.events[:0]
+	for _, e := range t.events {
+		msg := e.message
+		if len(msg) == 3 && msg[0] == 0xB0|ch && (msg[1] == 7 || msg[1] == 11) {
+			if msg[1] == 7 {
+				volume = int(msg[2])
+			} else {
+				expression = int(msg[2])
+			}
+			continue
+		}
+		if len(msg) == 3 && msg[0] == 0x90|ch && msg[2] > 0 {
+			v := (int(msg[2])*volume*expression + 16129/2) / 16129
+			if v < 1 {
+				v = 1
+			}
+			msg[2] = uint8(v)
+		}
+		events = append(events, e)
+	}
+	t.events = events
+}
@@ -0,0 +1,143 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ccValues(t *MIDITrack) []int {
+	var values []int
+	for _, e := range t.events {
+		if e.message[0]&0xF0 == 0xB0 {
+			values = append(values, int(e.message[2]))
+		}
+	}
+	return values
+}
+
+func TestDedupeControllers(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xB0, 64, 127}},
+		&MIDIEvent{tick: 10, message: []uint8{0xB0, 64, 127}},
+		&MIDIEvent{tick: 10, message: []uint8{0xB1, 64, 127}},
+		&MIDIEvent{tick: 20, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 30, message: []uint8{0xB0, 64, 127}},
+		&MIDIEvent{tick: 40, message: []uint8{0xB0, 64, 0}},
+		&MIDIEvent{tick: 50, message: []uint8{0xB0, 6, 2}},
+		&MIDIEvent{tick: 60, message: []uint8{0xB0, 6, 2}},
+		&MIDIEvent{tick: 70, message: []uint8{0xB0, 123, 0}},
+		&MIDIEvent{tick: 80, message: []uint8{0xB0, 123, 0}},
+	)
+
+	if n := track.DedupeControllers(0); n != 2 {
+		t.Errorf("removed %d events, want 2", n)
+	}
+	if track.Len() != 8 {
+		t.Errorf("%d events left, want 8", track.Len())
+	}
+}
+
+func TestDedupeControllersThreshold(t *testing.T) {
+	track := newTestTrack()
+	for i, v := range []uint8{10, 11, 12, 13, 14, 20, 21, 23} {
+		track.Append(&MIDIEvent{tick: int64(i), message: []uint8{0xB0, 1, v}})
+	}
+
+	if n := track.DedupeControllers(2); n != 4 {
+		t.Errorf("removed %d events, want 4", n)
+	}
+	got := ccValues(track)
+	want := []int{10, 13, 20, 23}
+	if len(got) != len(want) {
+		t.Fatalf("values = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("values = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestSustainRegions(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xB0, 64, 127}},
+		&MIDIEvent{tick: 100, message: []uint8{0xB0, 64, 100}},
+		&MIDIEvent{tick: 200, message: []uint8{0xB1, 64, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0xB0, 64, 10}},
+		&MIDIEvent{tick: 500, message: []uint8{0xB0, 64, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0xB0, 64, 64}},
+		&MIDIEvent{tick: 1440, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+
+	want := []SustainRegion{{0, 480}, {960, 1440}}
+	if got := track.SustainRegions(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("SustainRegions(0) = %v, want %v", got, want)
+	}
+	if got := track.SustainRegions(2); got != nil {
+		t.Errorf("SustainRegions(2) = %v, want nil", got)
+	}
+}
+
+func TestApplySustain(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0xB0, 64, 127}},
+		&MIDIEvent{tick: 100, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 200, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 300, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 400, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 450, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0xB0, 64, 0}},
+		&MIDIEvent{tick: 600, message: []uint8{0x90, 67, 100}},
+		&MIDIEvent{tick: 700, message: []uint8{0x80, 67, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	track.ApplySustain(0)
+
+	want := []Note{
+		{Key: 60, Velocity: 100, Start: 0, End: 480},
+		{Key: 64, Velocity: 100, Start: 200, End: 400},
+		{Key: 64, Velocity: 100, Start: 400, End: 480},
+		{Key: 67, Velocity: 100, Start: 600, End: 700},
+	}
+	if got := track.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %v, want %v", got, want)
+	}
+	if got := track.SustainRegions(0); got != nil {
+		t.Errorf("SustainRegions(0) = %v after ApplySustain", got)
+	}
+}
+
+func TestBakeVolumeIntoVelocity(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 127}},
+		&MIDIEvent{tick: 0, message: []uint8{0xB1, 7, 50}},
+		&MIDIEvent{tick: 100, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 100, message: []uint8{0xB0, 7, 127}},
+		&MIDIEvent{tick: 100, message: []uint8{0xB0, 11, 64}},
+		&MIDIEvent{tick: 200, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 200, message: []uint8{0x91, 62, 100}},
+		&MIDIEvent{tick: 300, message: []uint8{0x90, 62, 0}},
+		&MIDIEvent{tick: 300, message: []uint8{0x81, 62, 0}},
+		&MIDIEvent{tick: 300, message: []uint8{0xB0, 11, 0}},
+		&MIDIEvent{tick: 400, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 500, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 500, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	track.BakeVolumeIntoVelocity(0)
+
+	var got []int
+	for _, n := range track.Notes() {
+		got = append(got, n.Velocity)
+	}
+	if want := []int{100, 50, 100, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("velocities = %v, want %v", got, want)
+	}
+	if track.Len() != 10 {
+		t.Errorf("Len() = %d, want 10", track.Len())
+	}
+	if msg := track.At(1).Message(); msg[0] != 0xB1 {
+		t.Errorf("event 1 = % X, want the channel 1 volume kept", msg)
+	}
+}
@@ -0,0 +1,402 @@
+package midi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// chasedMetaTypes lists the meta events whose state carries over into a
+// cut region.
+var chasedMetaTypes = []uint8{0x51, 0x58, 0x59}
+
+// Cut returns a copy of the events in [start, end) moved to begin at tick
+// 0. The tempo, time signature and key signature in effect at start are
+// placed at the beginning of the first track. Notes still sounding at end
+// are closed there, and note offs of notes started before start are
+// dropped.
+func (d *MIDIData) Cut(start, end int64) *MIDIData {
+	out := &MIDIData{
+		Name:     d.Name,
+		Format:   d.Format,
+		Division: d.Division,
+	}
+
+	// Find the latest state events before the cut.
+	chased := make(map[uint8]*MIDIEvent)
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if e.tick >= start {
+				break
+			}
+			if len(e.message) < 2 || e.message[0] != 0xFF {
+				continue
+			}
+			for _, typ := range chasedMetaTypes {
+				if e.message[1] == typ &&
+					(chased[typ] == nil || e.tick >= chased[typ].tick) {
+					chased[typ] = e
+				}
+			}
+		}
+	}
+
+	for i, t := range d.tracks {
+		nt := &MIDITrack{Name: t.Name}
+		if i == 0 {
+			for _, typ := range chasedMetaTypes {
+				if e, ok := chased[typ]; ok {
+					c := e.clone()
+					c.tick = 0
+					nt.Append(c)
+				}
+			}
+		}
+
+		active := make(map[[2]int]int)
+		for _, e := range t.events {
+			if e.tick < start || e.tick >= end || isEndOfTrack(e.message) {
+				continue
+			}
+			if ch, key, on, ok := noteEvent(e.message); ok {
+				k := [2]int{ch, key}
+				if on {
+					active[k]++
+				} else if active[k] == 0 {
+					continue
+				} else {
+					active[k]--
+				}
+			}
+			c := e.clone()
+			c.tick -= start
+			nt.Append(c)
+		}
+
+		for _, e := range t.events {
+			if e.tick < start || e.tick >= end {
+				continue
+			}
+			ch, key, on, ok := noteEvent(e.message)
+			if !ok || !on {
+				continue
+			}
+			k := [2]int{ch, key}
+			for ; active[k] > 0; active[k]-- {
+				nt.Append(&MIDIEvent{
+					tick:    end - start,
+					message: []uint8{0x80 | uint8(ch), uint8(key), 0},
+				})
+			}
+		}
+		nt.Append(&MIDIEvent{
+			tick:    end - start,
+			message: []uint8{0xFF, 0x2F, 0x00},
+		})
+		out.Append(nt)
+	}
+
+	out.updateMaps()
+	return out
+}
+
+// noteEvent reports the channel and key of a note on or note off message.
+// A note on with zero velocity is reported as a note off.
+func noteEvent(message []uint8) (channel, key int, on, ok bool) {
+	if len(message) != 3 {
+		return 0, 0, false, false
+	}
+	switch message[0] & 0xF0 {
+	case 0x90:
+		return int(message[0] & 0x0F), int(message[1]), message[2] > 0, true
+	case 0x80:
+		return int(message[0] & 0x0F), int(message[1]), false, true
+	}
+	return 0, 0, false, false
+}
+
+// ShiftTime moves every event of the track by delta ticks. Events that
+// would move before tick 0 are placed at tick 0, keeping their order.
+func (t *MIDITrack) ShiftTime(delta int64) {
+	for _, e := range t.events {
+		e.tick += delta
+		if e.tick < 0 {
+			e.tick = 0
+		}
+	}
+}
+
+// ShiftTime moves every event of every track by delta ticks. Events that
+// would move before tick 0 are placed at tick 0, keeping their order.
+func (d *MIDIData) ShiftTime(delta int64) {
+	for _, t := range d.tracks {
+		t.ShiftTime(delta)
+	}
+	d.updateMaps()
+}
+
+// FirstNoteTick returns the tick of the earliest note on in any track, or
+// -1 if there are no notes.
+func (d *MIDIData) FirstNoteTick() int64 {
+	first := int64(-1)
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if _, _, on, ok := noteEvent(e.message); ok && on {
+				if first < 0 || e.tick < first {
+					first = e.tick
+				}
+				break
+			}
+		}
+	}
+	return first
+}
+
+// TrimLeadingSilence shifts all events earlier so that the first note
+// starts at tick 0. Events before the first note, such as the initial
+// tempo and time signature, end up at tick 0.
+func (d *MIDIData) TrimLeadingSilence() {
+	if first := d.FirstNoteTick(); first > 0 {
+		d.ShiftTime(-first)
+	}
+}
+
+// Resample changes the division to the given number of ticks per quarter
+// note, scaling every tick and rounding to the nearest tick. It reports
+// whether every tick was scaled exactly.
+func (d *MIDIData) Resample(division int) (bool, error) {
+	if d.Division&0x8000 > 0 {
+		return false, errors.New("cannot resample time-code division")
+	}
+	from := int64(d.Division & 0x7FFF)
+	if from == 0 {
+		return false, fmt.Errorf("%w %d", ErrBadDivision, from)
+	}
+	if division < 1 || division > 0x7FFF {
+		return false, fmt.Errorf("%w %d", ErrBadDivision, division)
+	}
+
+	to := int64(division)
+	exact := true
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			scaled := e.tick * to
+			if scaled%from != 0 {
+				exact = false
+			}
+			e.tick = (scaled + from/2) / from
+		}
+	}
+	d.Division = division
+	d.updateMaps()
+
+	return exact, nil
+}
+
+// ConvertToPPQ resamples the data to target ticks per quarter note, e.g.
+// to bring drum machine exports at 96 or 192 PPQ to 480. The returned
+// bool is false if some event didn't land exactly on a target tick and was
+// rounded, so the caller can decide whether to accept the result.
+func (d *MIDIData) ConvertToPPQ(target int) (bool, error) {
+	if d.Division == target {
+		return true, nil
+	}
+	return d.Resample(target)
+}
+
+// DedupeTracks removes every track whose events are identical to those of
+// an earlier track (see (*MIDITrack).Equal) and returns how many were
+// removed.
+func (d *MIDIData) DedupeTracks() int {
+	tracks := d.tracks[:0]
+	removed := 0
+	for _, t := range d.tracks {
+		duplicate := false
+		for _, kept := range tracks {
+			if kept.Equal(t) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			removed++
+			continue
+		}
+		tracks = append(tracks, t)
+	}
+	d.tracks = tracks
+	if removed > 0 {
+		d.updateMaps()
+	}
+	return removed
+}
+
+// AddFinalAllNotesOff gives every track with channel messages a clean
+// ending: at the track's last event it releases the notes still sounding
+// and sends All Notes Off (CC 123) on each channel the track uses, then
+// places the end of track at least extraTicks later so that release and
+// reverb tails aren't cut off. An end of track that is already later
+// stays where it is.
+func (d *MIDIData) AddFinalAllNotesOff(extraTicks int64) {
+	if extraTicks < 0 {
+		extraTicks = 0
+	}
+	for _, t := range d.tracks {
+		channels := t.Channels()
+		if len(channels) == 0 {
+			continue
+		}
+
+		var last int64
+		for _, e := range t.events {
+			if !isEndOfTrack(e.message) && e.tick > last {
+				last = e.tick
+			}
+		}
+		for _, n := range t.HangingNotes() {
+			t.insert(&MIDIEvent{tick: last, message: []uint8{
+				0x80 | uint8(n.Channel), uint8(n.Key), 0}})
+		}
+		for _, ch := range channels {
+			t.insert(&MIDIEvent{tick: last, message: []uint8{
+				0xB0 | uint8(ch), 123, 0}})
+		}
+
+		if n := len(t.events); isEndOfTrack(t.events[n-1].message) {
+			if end := last + extraTicks; t.events[n-1].tick < end {
+				t.events[n-1].tick = end
+			}
+		} else {
+			t.Append(&MIDIEvent{tick: last + extraTicks,
+				message: []uint8{0xFF, 0x2F, 0x00}})
+		}
+	}
+}
+
+// Repeat is a region of ticks [StartTick, EndTick) to be played Count
+// times in a row.
+type Repeat struct {
+	StartTick, EndTick int64
+	Count              int
+}
+
+// ExpandRepeats writes out the given repeats, so that data whose repeat
+// structure is known, e.g. from its markers, plays linearly: each region
+// is followed by Count-1 copies of itself and the events after it move
+// later. The data is rebuilt from Cut segments, so the tempo, time
+// signature and key signature in effect at the start of a region are in
+// effect again at the start of each copy, and notes sounding across a
+// region boundary are cut there. Regions must lie within the data and
+// must not overlap, and Count must be at least 1. Nothing is changed if an
+// error is returned.
+func (d *MIDIData) ExpandRepeats(repeats []Repeat) error {
+	sorted := make([]Repeat, len(repeats))
+	copy(sorted, repeats)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTick < sorted[j].StartTick
+	})
+
+	last := d.LastTick()
+	type segment struct {
+		start, end int64
+		count      int
+	}
+	var segments []segment
+	var pos int64
+	for _, r := range sorted {
+		if r.StartTick < 0 || r.StartTick >= r.EndTick || r.EndTick > last {
+			return fmt.Errorf("invalid repeat region [%d, %d)", r.StartTick, r.EndTick)
+		}
+		if r.Count < 1 {
+			return fmt.Errorf("invalid repeat count %d", r.Count)
+		}
+		if r.StartTick < pos {
+			return fmt.Errorf("repeat region [%d, %d) overlaps another",
+				r.StartTick, r.EndTick)
+		}
+		if r.StartTick > pos {
+			segments = append(segments, segment{pos, r.StartTick, 1})
+		}
+		segments = append(segments, segment{r.StartTick, r.EndTick, r.Count})
+		pos = r.EndTick
+	}
+	// The final segment includes the events at the last tick.
+	segments = append(segments, segment{pos, last + 1, 1})
+
+	events := make([][]*MIDIEvent, len(d.tracks))
+	state := make(map[uint8][]uint8) // chased meta events in effect
+	var offset int64
+	for _, s := range segments {
+		part := d.Cut(s.start, s.end)
+
+		// Of the state events at the start of the segment, which include
+		// those chased by Cut, only the last of each type matters.
+		first := make(map[uint8]*MIDIEvent)
+		latest := make(map[uint8]*MIDIEvent)
+		for _, t := range part.tracks {
+			for _, e := range t.events {
+				if !isChasedMeta(e.message) {
+					continue
+				}
+				typ := e.message[1]
+				if e.tick == 0 {
+					first[typ] = e
+				}
+				if latest[typ] == nil || e.tick >= latest[typ].tick {
+					latest[typ] = e
+				}
+			}
+		}
+
+		for n := 0; n < s.count; n++ {
+			for i, t := range part.tracks {
+				for _, e := range t.events {
+					if isEndOfTrack(e.message) {
+						continue
+					}
+					if e.tick == 0 && isChasedMeta(e.message) {
+						typ := e.message[1]
+						if e != first[typ] || bytes.Equal(state[typ], e.message) {
+							continue
+						}
+					}
+					c := e.clone()
+					c.tick += offset
+					events[i] = append(events[i], c)
+				}
+			}
+			for typ, e := range latest {
+				state[typ] = e.message
+			}
+			offset += s.end - s.start
+		}
+	}
+
+	end := offset - 1
+	for i, t := range d.tracks {
+		tick := end
+		if n := len(events[i]); n > 0 && events[i][n-1].tick > tick {
+			tick = events[i][n-1].tick
+		}
+		t.events = append(events[i], &MIDIEvent{
+			tick:    tick,
+			message: []uint8{0xFF, 0x2F, 0x00},
+		})
+	}
+	d.updateMaps()
+	return nil
+}
+
+// isChasedMeta reports whether message is one of the chasedMetaTypes.
+func isChasedMeta(message []uint8) bool {
+	if len(message) < 2 || message[0] != 0xFF {
+		return false
+	}
+	for _, typ := range chasedMetaTypes {
+		if message[1] == typ {
+			return true
+		}
+	}
+	return false
+}
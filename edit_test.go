@@ -0,0 +1,233 @@
+package midi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTrimLeadingSilence(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x58, 0x04, 3, 2, 24, 8}},
+			&MIDIEvent{tick: 1440, message: tempoMessage(60)},
+			&MIDIEvent{tick: 2400, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xC0, 1}},
+			&MIDIEvent{tick: 960, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 1440, message: []uint8{0x80, 60, 0}},
+		),
+	)
+
+	if got := d.FirstNoteTick(); got != 960 {
+		t.Fatalf("FirstNoteTick() = %d, want 960", got)
+	}
+
+	d.TrimLeadingSilence()
+
+	if got := d.FirstNoteTick(); got != 0 {
+		t.Errorf("FirstNoteTick() = %d after trimming, want 0", got)
+	}
+	wantTicks := []int64{0, 0, 480, 1440}
+	for i, want := range wantTicks {
+		if got := d.At(0).At(i).Tick(); got != want {
+			t.Errorf("conductor event %d at %d, want %d", i, got, want)
+		}
+	}
+	if got := d.At(1).At(2).Tick(); got != 480 {
+		t.Errorf("note off at %d, want 480", got)
+	}
+	if got := d.MicrosPerQuarterAt(480); got != 1000000 {
+		t.Errorf("tempo at 480 = %d, want 1000000", got)
+	}
+
+	empty := newTestData(480, newTestTrack())
+	if got := empty.FirstNoteTick(); got != -1 {
+		t.Errorf("FirstNoteTick() of empty data = %d, want -1", got)
+	}
+}
+
+func TestConvertToPPQ(t *testing.T) {
+	newData := func() *MIDIData {
+		return newTestData(96, newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 24, message: []uint8{0x99, 36, 100}},
+			&MIDIEvent{tick: 31, message: []uint8{0x89, 36, 0}},
+			&MIDIEvent{tick: 96, message: []uint8{0xFF, 0x2F, 0x00}},
+		))
+	}
+
+	d := newData()
+	exact, err := d.ConvertToPPQ(480)
+	if err != nil || !exact {
+		t.Fatalf("ConvertToPPQ(480) = %v, %v; want true, nil", exact, err)
+	}
+	if d.Division != 480 {
+		t.Errorf("Division = %d, want 480", d.Division)
+	}
+	for i, want := range []int64{0, 120, 155, 480} {
+		if got := d.At(0).At(i).Tick(); got != want {
+			t.Errorf("event %d at %d, want %d", i, got, want)
+		}
+	}
+	if got := d.TickToSeconds(480); got != 0.5 {
+		t.Errorf("TickToSeconds(480) = %f, want 0.5", got)
+	}
+
+	d = newData()
+	exact, err = d.ConvertToPPQ(48)
+	if err != nil || exact {
+		t.Fatalf("ConvertToPPQ(48) = %v, %v; want false, nil", exact, err)
+	}
+	if got := d.At(0).At(2).Tick(); got != 16 {
+		t.Errorf("rounded tick = %d, want 16", got)
+	}
+
+	if _, err := newData().ConvertToPPQ(0); err == nil {
+		t.Errorf("ConvertToPPQ(0) should fail")
+	}
+}
+
+func TestDedupeTracks(t *testing.T) {
+	newTrack := func(key uint8) *MIDITrack {
+		return newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, key, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, key, 0}},
+		)
+	}
+	a, b := newTrack(60), newTrack(60)
+	b.Name = "copy"
+	shifted := newTrack(60)
+	shifted.ShiftTime(1)
+
+	d := newTestData(480, a, newTrack(62), b, shifted, newTrack(62))
+	if n := d.DedupeTracks(); n != 2 {
+		t.Errorf("removed %d tracks, want 2", n)
+	}
+	if d.Len() != 3 || d.At(0) != a || d.At(2) != shifted {
+		t.Errorf("wrong tracks kept")
+	}
+	if d.DedupeTracks() != 0 {
+		t.Errorf("second DedupeTracks should remove nothing")
+	}
+}
+
+func TestAddFinalAllNotesOff(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 0, message: []uint8{0x91, 64, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x81, 64, 0}},
+			&MIDIEvent{tick: 960, message: []uint8{0xB1, 7, 90}},
+			&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+	)
+	d.AddFinalAllNotesOff(480)
+
+	if got := d.At(0).Len(); got != 2 {
+		t.Errorf("conductor track has %d events, want 2", got)
+	}
+
+	track := d.At(1)
+	if hanging := track.HangingNotes(); len(hanging) != 0 {
+		t.Errorf("HangingNotes() = %v, want none", hanging)
+	}
+	want := [][]uint8{
+		{0x80, 60, 0},
+		{0xB0, 123, 0},
+		{0xB1, 123, 0},
+		{0xFF, 0x2F, 0x00},
+	}
+	for i, msg := range want {
+		e := track.At(track.Len() - len(want) + i)
+		if !bytes.Equal(e.Message(), msg) {
+			t.Errorf("event %d = % X, want % X", i, e.Message(), msg)
+		}
+	}
+	if tick := track.At(track.Len() - 1).Tick(); tick != 1440 {
+		t.Errorf("end of track at %d, want 1440", tick)
+	}
+
+	// Trailing silence beyond extraTicks is kept.
+	d = newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 3840, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+	d.AddFinalAllNotesOff(480)
+	track = d.At(0)
+	if e := track.At(track.Len() - 1); !isEndOfTrack(e.Message()) || e.Tick() != 3840 {
+		t.Errorf("last event = %d % X, want end of track at 3840", e.Tick(), e.Message())
+	}
+}
+
+func TestExpandRepeats(t *testing.T) {
+	newData := func() *MIDIData {
+		return newTestData(480,
+			newTestTrack(
+				&MIDIEvent{tick: 0, message: tempoMessage(120)},
+				&MIDIEvent{tick: 1920, message: tempoMessage(90)},
+				&MIDIEvent{tick: 3840, message: []uint8{0xFF, 0x2F, 0x00}},
+			),
+			newTestTrack(
+				&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+				&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+				&MIDIEvent{tick: 1920, message: []uint8{0x90, 62, 100}},
+				&MIDIEvent{tick: 2400, message: []uint8{0x80, 62, 0}},
+				&MIDIEvent{tick: 3360, message: []uint8{0x90, 64, 100}},
+				&MIDIEvent{tick: 3840, message: []uint8{0x80, 64, 0}},
+				&MIDIEvent{tick: 3840, message: []uint8{0xFF, 0x2F, 0x00}},
+			))
+	}
+
+	d := newData()
+	if err := d.ExpandRepeats([]Repeat{{StartTick: 1920, EndTick: 3840, Count: 2}}); err != nil {
+		t.Fatalf("ExpandRepeats() error: %v", err)
+	}
+	want := [][2]int64{{0, 480}, {1920, 2400}, {3360, 3840}, {3840, 4320}, {5280, 5760}}
+	if got := spans(d.At(1).Notes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("note spans = %v, want %v", got, want)
+	}
+	var tempos []uint64
+	for _, c := range d.TempoChanges() {
+		tempos = append(tempos, c.Count)
+	}
+	if want := []uint64{0, 1920}; !reflect.DeepEqual(tempos, want) {
+		t.Errorf("tempo changes at %v, want %v", tempos, want)
+	}
+	if got := d.LastTick(); got != 5760 {
+		t.Errorf("LastTick() = %d, want 5760", got)
+	}
+
+	// Repeating the first bar restores its tempo for the second bar.
+	d = newData()
+	if err := d.ExpandRepeats([]Repeat{{StartTick: 0, EndTick: 1920, Count: 2}}); err != nil {
+		t.Fatalf("ExpandRepeats() error: %v", err)
+	}
+	if got := d.MicrosPerQuarterAt(1920); got != 500000 {
+		t.Errorf("MicrosPerQuarterAt(1920) = %d, want 500000", got)
+	}
+	if got := d.MicrosPerQuarterAt(3840); got != 666667 {
+		t.Errorf("MicrosPerQuarterAt(3840) = %d, want 666667", got)
+	}
+
+	for _, repeats := range [][]Repeat{
+		{{StartTick: 0, EndTick: 1920, Count: 0}},
+		{{StartTick: 0, EndTick: 5000, Count: 2}},
+		{{StartTick: 1920, EndTick: 3840, Count: 2}, {StartTick: 0, EndTick: 2400, Count: 2}},
+	} {
+		d := newData()
+		if err := d.ExpandRepeats(repeats); err == nil {
+			t.Errorf("ExpandRepeats(%v) succeeded", repeats)
+		}
+		if orig := newData(); !d.At(0).Equal(orig.At(0)) || !d.At(1).Equal(orig.At(1)) {
+			t.Errorf("ExpandRepeats(%v) changed the data on error", repeats)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package midi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned while reading MIDI files. They may be wrapped with more
+// detail, so test for them with errors.Is.
+var (
+	// ErrNotMIDI means the data doesn't start with an MThd chunk.
+	ErrNotMIDI = errors.New("not a MIDI file")
+	// ErrBadHeaderLength means the MThd chunk length isn't 6.
+	ErrBadHeaderLength = errors.New("invalid header length")
+	// ErrUnknownFormat means the header declares a format other than 0, 1
+	// or 2.
+	ErrUnknownFormat = errors.New("unknown format")
+	// ErrBadTrackCount means the number of tracks doesn't fit the format.
+	ErrBadTrackCount = errors.New("invalid number of tracks")
+	// ErrBadDivision means the header declares a division that can't be
+	// used for timing, such as 0 ticks per quarter note.
+	ErrBadDivision = errors.New("invalid division")
+	// ErrTruncated means the data ends in the middle of a chunk or event.
+	ErrTruncated = errors.New("truncated data")
+	// ErrBadEvent means an event can't be decoded.
+	ErrBadEvent = errors.New("invalid event")
+	// ErrMissingEndOfTrack means a track chunk doesn't end with an end of
+	// track event.
+	ErrMissingEndOfTrack = errors.New("missing end of track")
+	// ErrDataAfterEndOfTrack means a track chunk has events after its end
+	// of track event.
+	ErrDataAfterEndOfTrack = errors.New("data after end of track")
+	// ErrTooLarge means the input exceeds the size limit given to
+	// ReadLimited or by ReadOptions.MaxBytes.
+	ErrTooLarge = errors.New("input too large")
+)
+
+// BadChunkError is returned when a chunk has an unexpected type.
+type BadChunkError struct {
+	Type   string // the chunk type found
+	Offset int64  // byte offset of the chunk
+}
+
+func (e *BadChunkError) Error() string {
+	return fmt.Sprintf("invalid chunk type %q at offset %d", e.Type, e.Offset)
+}
+
+// Warning describes a problem in a MIDI file that was repaired while
+// reading it.
+type Warning struct {
+	Track   int    // index of the track, or -1 for the header
+	Offset  int64  // byte offset in the file
+	Message string // what was repaired
+}
+
+func (w Warning) String() string {
+	if w.Track < 0 {
+		return fmt.Sprintf("offset %d: %s", w.Offset, w.Message)
+	}
+	return fmt.Sprintf("track %d, offset %d: %s", w.Track, w.Offset, w.Message)
+}
+
+// Issue describes a problem found in MIDI data by a check such as
+// GMCompliance.
+type Issue struct {
+	Track   int    // index of the track, or -1 for the whole data
+	Channel int    // channel, or -1 if the problem isn't on a channel
+	Tick    int64  // first tick at which the problem occurs
+	Message string // what is wrong
+
+	// Confidence is how likely a heuristic check is to be right, from 0
+	// to 1. Definite problems have confidence 1.
+	Confidence float64
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("tick %d: %s", i.Tick, i.Message)
+	if i.Confidence < 1 {
+		s += fmt.Sprintf(" (confidence %.2f)", i.Confidence)
+	}
+	if i.Channel >= 0 {
+		s = fmt.Sprintf("channel %d, %s", i.Channel, s)
+	}
+	if i.Track >= 0 {
+		s = fmt.Sprintf("track %d, %s", i.Track, s)
+	}
+	return s
+}
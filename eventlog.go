@@ -0,0 +1,50 @@
+package midi
+
+// LogEntry is a decoded event with its position in time.
+type LogEntry struct {
+	Track   int
+	Tick    int64
+	Seconds float64
+	Message Message
+}
+
+// EventLog decodes every event of every track and returns them in tick
+// order with their times in seconds. Events at the same tick are ordered
+// as by Walk: meta and system exclusive events first, then by track, each
+// track keeping its own order. End of track events and events that fail
+// to decode are left out. The log is built in a single pass of Walk that
+// advances through the tempo map alongside the events, so it takes time
+// proportional to the number of events times the number of tracks, plus
+// the tempo changes, and holds every decoded event in memory; use Walk or
+// WriteJSONL to stream large data instead.
+func (d *MIDIData) EventLog() []LogEntry {
+	tempo := d.TempoChanges()
+	var log []LogEntry
+	var seconds float64
+	var last int64
+	next := 1
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		if isEndOfTrack(e.message) {
+			return true
+		}
+		msg, err := ParseMessage(e.message)
+		if err != nil {
+			return true
+		}
+
+		for next < len(tempo) && int64(tempo[next].Count) <= e.tick {
+			seconds += float64(int64(tempo[next].Count)-last) *
+				tempo[next-1].TickSeconds
+			last = int64(tempo[next].Count)
+			next++
+		}
+		log = append(log, LogEntry{
+			Track:   track,
+			Tick:    e.tick,
+			Seconds: seconds + float64(e.tick-last)*tempo[next-1].TickSeconds,
+			Message: msg,
+		})
+		return true
+	})
+	return log
+}
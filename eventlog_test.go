@@ -0,0 +1,46 @@
+package midi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEventLog(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 960, message: tempoMessage(60)},
+			&MIDIEvent{tick: 1920, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 960, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 1440, message: []uint8{0xF3}},
+			&MIDIEvent{tick: 1440, message: []uint8{0xB0, 7, 100}},
+		),
+	)
+
+	log := d.EventLog()
+	want := []LogEntry{
+		{0, 0, 0, MetaEvent{Type: 0x51, Data: []byte{0x07, 0xA1, 0x20}}},
+		{1, 0, 0, NoteOn{Channel: 0, Key: 60, Velocity: 100}},
+		{0, 960, 1, MetaEvent{Type: 0x51, Data: []byte{0x0F, 0x42, 0x40}}},
+		{1, 960, 1, NoteOff{Channel: 0, Key: 60, Velocity: 0}},
+		{1, 1440, 2, ControlChange{Channel: 0, Controller: 7, Value: 100}},
+	}
+	if len(log) != len(want) {
+		t.Fatalf("EventLog() = %v, want %v", log, want)
+	}
+	for i := range want {
+		got := log[i]
+		if got.Track != want[i].Track || got.Tick != want[i].Tick ||
+			math.Abs(got.Seconds-want[i].Seconds) > 1e-9 ||
+			!reflect.DeepEqual(got.Message, want[i].Message) {
+			t.Errorf("entry %d = %+v, want %+v", i, got, want[i])
+		}
+		if s := d.TickToSeconds(got.Tick); math.Abs(got.Seconds-s) > 1e-9 {
+			t.Errorf("entry %d: Seconds = %g, TickToSeconds = %g", i, got.Seconds, s)
+		}
+	}
+}
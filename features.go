@@ -0,0 +1,124 @@
+package midi
+
+import (
+	"math"
+	"sort"
+)
+
+// NoteTensors returns the notes of a track as aligned slices, one element
+// per note in note on order: the key, the start time and duration in
+// seconds (through the tempo map of the data), and the velocity. If
+// normalized is set, times are instead expressed as fractions of the
+// track length, so that they fall in 0..1.
+func (d *MIDIData) NoteTensors(track int, normalized bool) (pitches, starts,
+	durations, velocities []float64) {
+	t := d.tracks[track]
+	notes := t.Notes()
+
+	scale := 1.0
+	if length := d.TickToSeconds(t.lastTick()); normalized && length > 0 {
+		scale = 1 / length
+	}
+
+	pitches = make([]float64, len(notes))
+	starts = make([]float64, len(notes))
+	durations = make([]float64, len(notes))
+	velocities = make([]float64, len(notes))
+	for i, n := range notes {
+		start := d.TickToSeconds(n.Start)
+		pitches[i] = float64(n.Key)
+		starts[i] = start * scale
+		durations[i] = (d.TickToSeconds(n.End) - start) * scale
+		velocities[i] = float64(n.Velocity)
+	}
+	return pitches, starts, durations, velocities
+}
+
+// Chromagram returns a beat-synchronous chroma feature: for each frame of
+// beatsPerFrame beats, the energy of each of the 12 pitch classes, C
+// first. Beats follow the time signature map, so a beat of 6/8 is an
+// eighth note, and frames restart at each time signature change. A note
+// contributes its sounding time within the frame in seconds, through the
+// tempo map, weighted by its velocity divided by 127. Notes on
+// PercussionChannel are ignored. If normalized is set, each frame with
+// any energy is scaled to sum to 1. It returns nil for time-code division
+// or if a frame would be shorter than one tick.
+func (d *MIDIData) Chromagram(beatsPerFrame float64, normalized bool) [][12]float64 {
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 || beatsPerFrame <= 0 {
+		return nil
+	}
+
+	var notes []Note
+	end := d.LastTick()
+	for _, t := range d.tracks {
+		for _, n := range t.Notes() {
+			if n.Channel == PercussionChannel {
+				continue
+			}
+			notes = append(notes, n)
+			if n.End > end {
+				end = n.End
+			}
+		}
+	}
+
+	// Frame boundaries, ending with the end of the data.
+	var bounds []int64
+	sigs := d.TimeSignatures()
+	for i, ts := range sigs {
+		stop := end
+		if i+1 < len(sigs) && int64(sigs[i+1].Count) < stop {
+			stop = int64(sigs[i+1].Count)
+		}
+		frame := beatsPerFrame * float64(d.Division&0x7FFF) * 4 / float64(ts.BeatUnit)
+		if frame < 1 {
+			return nil
+		}
+		for k := 0; ; k++ {
+			tick := int64(ts.Count) + int64(math.Floor(float64(k)*frame+0.5))
+			if tick >= stop {
+				break
+			}
+			if n := len(bounds); n == 0 || bounds[n-1] < tick {
+				bounds = append(bounds, tick)
+			}
+		}
+	}
+	if len(bounds) == 0 {
+		return nil
+	}
+	bounds = append(bounds, end)
+
+	frames := make([][12]float64, len(bounds)-1)
+	for _, n := range notes {
+		i := sort.Search(len(bounds), func(i int) bool {
+			return bounds[i] > n.Start
+		}) - 1
+		for ; i < len(frames) && bounds[i] < n.End; i++ {
+			start, stop := bounds[i], bounds[i+1]
+			if n.Start > start {
+				start = n.Start
+			}
+			if n.End < stop {
+				stop = n.End
+			}
+			seconds := d.TickToSeconds(stop) - d.TickToSeconds(start)
+			frames[i][n.Key%12] += seconds * float64(n.Velocity) / 127
+		}
+	}
+
+	if normalized {
+		for i := range frames {
+			var sum float64
+			for _, v := range frames[i] {
+				sum += v
+			}
+			if sum > 0 {
+				for pc := range frames[i] {
+					frames[i][pc] /= sum
+				}
+			}
+		}
+	}
+	return frames
+}
@@ -0,0 +1,66 @@
+package midi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNoteTensors(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 960, message: tempoMessage(60)},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 960, message: []uint8{0x90, 67, 80}},
+			&MIDIEvent{tick: 1440, message: []uint8{0x80, 67, 0}},
+			&MIDIEvent{tick: 1920, message: []uint8{0xFF, 0x2F, 0x00}},
+		))
+
+	pitches, starts, durations, velocities := d.NoteTensors(1, false)
+	if !reflect.DeepEqual(pitches, []float64{60, 67}) ||
+		!reflect.DeepEqual(starts, []float64{0, 1}) ||
+		!reflect.DeepEqual(durations, []float64{0.5, 1}) ||
+		!reflect.DeepEqual(velocities, []float64{100, 80}) {
+		t.Errorf("NoteTensors = %v %v %v %v",
+			pitches, starts, durations, velocities)
+	}
+
+	_, starts, durations, _ = d.NoteTensors(1, true)
+	if !reflect.DeepEqual(starts, []float64{0, 1.0 / 3}) ||
+		!reflect.DeepEqual(durations, []float64{0.5 / 3, 1.0 / 3}) {
+		t.Errorf("normalized NoteTensors = %v %v", starts, durations)
+	}
+}
+
+func TestChromagram(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 127}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 67, 127}},
+		&MIDIEvent{tick: 240, message: []uint8{0x89, 67, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 64, 127}},
+		&MIDIEvent{tick: 720, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 64, 0}},
+	))
+
+	var beat0, beat1 [12]float64
+	beat0[0] = 0.5
+	beat1[0], beat1[4] = 0.25, 0.5
+	if got := d.Chromagram(1, false); !reflect.DeepEqual(got, [][12]float64{beat0, beat1}) {
+		t.Errorf("Chromagram(1, false) = %v", got)
+	}
+
+	got := d.Chromagram(2, true)
+	if len(got) != 1 || math.Abs(got[0][0]-0.6) > 1e-9 || math.Abs(got[0][4]-0.4) > 1e-9 {
+		t.Errorf("Chromagram(2, true) = %v, want C 0.6 and E 0.4", got)
+	}
+	if got := d.Chromagram(0, false); got != nil {
+		t.Errorf("Chromagram(0, false) = %v, want nil", got)
+	}
+	if got := d.Chromagram(1e-9, false); got != nil {
+		t.Errorf("Chromagram(1e-9, false) = %v, want nil", got)
+	}
+}
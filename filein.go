@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 )
 
@@ -28,18 +30,84 @@ type MIDIFile struct {
 	tempoEvents     []TempoChange
 	trackCounters   []uint64
 	trackTempoIndex []int
+	tracksEnd       int64
 	rawData         []byte
+	options         ReadOptions
+	warnings        []Warning
+	warned          map[int64]bool
+	formatRepaired  bool
+	recovering      bool    // set by ReadRecover
+	recovered       []error // problems worked around by ReadRecover
 }
 
+// ReadOptions controls how MIDI files are read.
+type ReadOptions struct {
+	// Charset decodes the text of meta events such as lyrics and text
+	// events. If nil, Latin1 is used as the SMF specification suggests.
+	Charset Charset
+
+	// StrictDataBytes makes a data byte with the high bit set inside a
+	// channel message an error. Otherwise the byte is masked to 7 bits
+	// and a warning is recorded.
+	StrictDataBytes bool
+
+	// Lenient repairs structural problems that are otherwise errors,
+	// recording a warning for each: an MThd chunk whose length field
+	// isn't 6, the only valid value, is read as if it were 6; a
+	// time-code division with an unknown frame rate is read as 30 frames
+	// per second; a track chunk longer than the rest of the file is cut
+	// short; data bytes found where a status byte is needed, with no
+	// running status to apply, are skipped; a track without an end of
+	// track event is accepted; and data after the end of track event is
+	// kept. Without it these are errors.
+	Lenient bool
+
+	// RepairFormat reads a file whose header claims format 0 but which
+	// has more than one track, a common exporter bug, as format 1 and
+	// records a warning. See MIDIFile.FormatRepaired.
+	RepairFormat bool
+
+	// Tracks, if not empty, lists the tracks whose events are wanted.
+	// The events of the other tracks are neither checked nor built into
+	// MIDIData, where they appear as empty tracks so that track numbers
+	// don't change. The header and chunk boundaries of every track, and
+	// the tempo map of the first track returned by TempoChanges, are
+	// still read, and in format 0 and 1 MIDIData keeps the tempo and time
+	// signature events of the first track. NextEvent reports the other
+	// tracks as empty.
+	Tracks []int
+
+	// TrackOffsets records the byte offset of each event in the file,
+	// available from MIDIEvent.SourceOffset.
+	TrackOffsets bool
+
+	// MaxBytes, if positive, makes reading fail with ErrTooLarge instead
+	// of reading more than MaxBytes from the reader. See ReadLimited.
+	MaxBytes int64
+}
+
+// TimeSignature represents a time signature event.
 type TimeSignature struct {
-	Count      uint64
-	BeatPerBar int
+	Count      uint64 // tick
+	BeatPerBar int    // numerator
+	BeatUnit   int    // denominator, e.g. 4 for a quarter note
 }
 
 // TempoChanage represents a tempo change event.
 type TempoChange struct {
-	Count       uint64  // tick
-	TickSeconds float64 // tick per seconds
+	Count            uint64  // tick
+	TickSeconds      float64 // tick per seconds
+	MicrosPerQuarter uint32  // raw tempo value of the set tempo event
+}
+
+// defaultMicrosPerQuarter is the tempo assumed until a set tempo event is
+// seen, i.e. 120 beats per minute.
+const defaultMicrosPerQuarter = 500000
+
+// tempoValue returns the microseconds per quarter note of a set tempo
+// event.
+func tempoValue(event []byte) uint32 {
+	return uint32(event[3])<<16 | uint32(event[4])<<8 | uint32(event[5])
 }
 
 func ReadMIDI(filename string) (*MIDIFile, error) {
@@ -54,16 +122,95 @@ func ReadMIDI(filename string) (*MIDIFile, error) {
 
 // Read reads MIDI data from an io.Reader.
 func Read(r io.Reader) (*MIDIFile, error) {
-	b, err := ioutil.ReadAll(r)
+	return ReadWithOptions(r, ReadOptions{})
+}
+
+// ReadWithOptions reads MIDI data from an io.Reader with the given
+// options.
+func ReadWithOptions(r io.Reader, opts ReadOptions) (*MIDIFile, error) {
+	var b []byte
+	var err error
+	if opts.MaxBytes > 0 {
+		b, err = readLimited(r, opts.MaxBytes)
+	} else {
+		b, err = ioutil.ReadAll(r)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	return parse(b, opts)
+}
+
+// ReadLimited is like Read but fails with ErrTooLarge instead of reading
+// more than maxBytes from r, so that untrusted input can't exhaust
+// memory. Use ReadOptions.MaxBytes to combine the limit with other
+// options.
+func ReadLimited(r io.Reader, maxBytes int64) (*MIDIFile, error) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	b, err := readLimited(r, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return parse(b, ReadOptions{})
+}
+
+// readLimited reads all of r, failing with ErrTooLarge if it holds more
+// than maxBytes.
+func readLimited(r io.Reader, maxBytes int64) ([]byte, error) {
+	if maxBytes == math.MaxInt64 {
+		return ioutil.ReadAll(r)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxBytes)
+	}
+	return b, nil
+}
+
+// ReadRecover reads a damaged file as far as possible instead of failing
+// at the first problem. The header must be intact. When a track chunk
+// isn't where the previous chunk's length says, the data is scanned for
+// the next "MTrk" from the start of the previous track's data, and the
+// previous track is cut or extended to it. A track length running past
+// the end of the file is cut at the next "MTrk" or the end of the file,
+// and a track is cut short before an event that can't be decoded. Tracks
+// that can't be found are dropped, reducing NumTracks. The problems are
+// returned as errors along with the file, whose data may be incomplete.
+// If nothing could be recovered the file is nil.
+func ReadRecover(r io.Reader) (*MIDIFile, []error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	m := &MIDIFile{
+		rawData:    b,
+		recovering: true,
+	}
+	if err := m.parseRawData(); err != nil {
+		return nil, append(m.recovered, err)
+	}
+	if m.NumTracks == 0 && len(m.recovered) > 0 {
+		return nil, m.recovered
+	}
+	return m, m.recovered
+}
+
+// parse parses the complete contents of a MIDI file.
+func parse(b []byte, opts ReadOptions) (*MIDIFile, error) {
 	m := &MIDIFile{
 		rawData: b,
+		options: opts,
 	}
 
-	err = m.parseRawData()
+	err := m.parseRawData()
 	if err != nil {
 		return nil, err
 	}
@@ -71,51 +218,102 @@ func Read(r io.Reader) (*MIDIFile, error) {
 	return m, nil
 }
 
-func (m *MIDIFile) parseRawData() error {
-	if m.rawData == nil {
-		return errors.New("raw data must be non-nil")
+// ReadHeader reads only the 14-byte MThd chunk from r and returns the
+// format, the number of tracks and the division, without reading any
+// track. It is meant for quickly indexing large collections of files.
+func ReadHeader(r io.Reader) (format, numTracks, division int, err error) {
+	b := make([]byte, 14)
+	n, err := io.ReadFull(r, b)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		if n < 4 || string(b[0:4]) != "MThd" {
+			return 0, 0, 0, ErrNotMIDI
+		}
+		return 0, 0, 0, ErrTruncated
 	}
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return parseHeader(b, ReadOptions{})
+}
 
-	// just alias
-	b := m.rawData
-
-	chunkType := string(b[0:4])
-	if chunkType != "MThd" {
-		return errors.New("invalid header: " + chunkType +
-			". Expected to be MThd.")
+// parseHeader parses and validates the MThd chunk at the start of b. The
+// Lenient and RepairFormat options relax the checks of the chunk length,
+// the frame rate and the number of tracks of format 0.
+func parseHeader(b []byte, opts ReadOptions) (format, numTracks, division int, err error) {
+	if len(b) < 4 || string(b[0:4]) != "MThd" {
+		return 0, 0, 0, ErrNotMIDI
+	}
+	if len(b) < 14 {
+		return 0, 0, 0, ErrTruncated
 	}
 
 	// NOTE that MIDI files are BIG endians.
 	// http://www.music.mcgill.ca/~gary/306/week9/smf.html
-	var length int32
-	binary.Read(bytes.NewReader(b[4:8]), binary.BigEndian, &length)
-
-	if length != 6 {
-		return errors.New("doesn't appear to be a MIDI file.")
+	length := int32(binary.BigEndian.Uint32(b[4:8]))
+	if length != 6 && !opts.Lenient {
+		return 0, 0, 0, fmt.Errorf("%w: %d", ErrBadHeaderLength, length)
 	}
 
 	// Read the MIDI file format.
-	var format int16
-	binary.Read(bytes.NewReader(b[8:10]), binary.BigEndian, &format)
-
+	format = int(int16(binary.BigEndian.Uint16(b[8:10])))
 	if format < 0 || format > 2 {
-		return errors.New("invalid format: " + string(format))
+		return 0, 0, 0, fmt.Errorf("%w: %d", ErrUnknownFormat, format)
 	}
-	m.Format = int(format)
 
 	// Read the number of tracks
-	var numTracks int16
-	binary.Read(bytes.NewReader(b[10:12]), binary.BigEndian, &numTracks)
-	m.NumTracks = int(numTracks)
+	numTracks = int(int16(binary.BigEndian.Uint16(b[10:12])))
+	if numTracks < 0 || (format == 0 && numTracks == 0) ||
+		(format == 0 && numTracks > 1 && !opts.RepairFormat) {
+		return 0, 0, 0, fmt.Errorf("%w: %d for format %d", ErrBadTrackCount,
+			numTracks, format)
+	}
 
-	if format == 0 && numTracks != 1 {
-		return errors.New("invalid number of tracks (>0) for a file format = 0! ")
+	// Read the beat division. For metrical time it is the number of
+	// ticks per quarter note, which must be 1 to 0x7FFF.
+	// For time-code based timing the upper byte is the negated SMPTE
+	// format and the lower byte the ticks per frame, which can't be 0.
+	division = int(int16(binary.BigEndian.Uint16(b[12:14])))
+	if division&0x8000 == 0 && division&0x7FFF == 0 ||
+		division&0x8000 != 0 && division&0xFF == 0 {
+		return 0, 0, 0, fmt.Errorf("%w %d", ErrBadDivision, division)
+	}
+	if !validDivision(division) && !opts.Lenient {
+		return 0, 0, 0, fmt.Errorf("%w: unknown frame rate %d", ErrBadDivision,
+			-int(int8(division>>8)))
 	}
 
-	// Read the beat division.
-	var division int16
-	binary.Read(bytes.NewReader(b[12:14]), binary.BigEndian, &division)
-	m.Division = int(division)
+	return format, numTracks, division, nil
+}
+
+func (m *MIDIFile) parseRawData() error {
+	if m.rawData == nil {
+		return errors.New("raw data must be non-nil")
+	}
+
+	// just alias
+	b := m.rawData
+
+	format, numTracks, division, err := parseHeader(b, m.options)
+	if err != nil {
+		return err
+	}
+	if length := binary.BigEndian.Uint32(b[4:8]); length != 6 {
+		m.warn(-1, 4, fmt.Sprintf("header length %d, assuming 6", length))
+	}
+	if format == 0 && numTracks > 1 {
+		format = 1
+		m.formatRepaired = true
+		m.warn(-1, 8, fmt.Sprintf("format 0 with %d tracks, read as format 1",
+			numTracks))
+	}
+	if !validDivision(division) {
+		m.warn(-1, 12, fmt.Sprintf("unknown frame rate %d, assuming 30",
+			-int(int8(division>>8))))
+		division, _ = SMPTEDivision(30, division&0xFF)
+	}
+	m.Format = format
+	m.NumTracks = numTracks
+	m.Division = division
 
 	var tickrate float64
 	m.UsingTimeCode = false
@@ -124,15 +322,12 @@ func (m *MIDIFile) parseRawData() error {
 	// see http://www.sonicspot.com/guide/midifiles.html what this code do
 	if m.Division&0x8000 > 0 {
 		// Determine ticks per second from time-code formats.
-		tickrate = float64(-int(m.Division) & 0x7F00)
-		// If frames per second value is 29, it really should be 29.97.
-		if tickrate == 29.0 {
-			tickrate = 29.97
-		}
-		tickrate *= float64(m.Division & 0x00FF)
+		fps, _ := m.SMPTEFormat()
+		tickrate = fps * float64(m.Division&0x00FF)
 		m.UsingTimeCode = true
 	} else {
-		tickrate = float64(m.Division & 0x00FF)
+		// Metrical time: ticks per quarter note.
+		tickrate = float64(m.Division & 0x7FFF)
 	}
 
 	// Now locate the track offsets and lengths.  If not using time
@@ -146,10 +341,38 @@ func (m *MIDIFile) parseRawData() error {
 	m.trackLengths = make([]int64, m.NumTracks)
 	m.trackStatus = make([]byte, m.NumTracks)
 	for i := 0; i < m.NumTracks; i++ {
-		chunkType := string(b[bitIndex : bitIndex+4])
-		if chunkType != "MTrk" {
-			return errors.New("invalid track header: " + chunkType +
-				". Expected to be MTrk.")
+		var chunkErr error
+		if bitIndex+8 > int64(len(b)) {
+			chunkErr = fmt.Errorf("track %d: %w", i, ErrTruncated)
+		} else if chunkType := string(b[bitIndex : bitIndex+4]); chunkType != "MTrk" {
+			chunkErr = &BadChunkError{Type: chunkType, Offset: bitIndex}
+		}
+		if chunkErr != nil {
+			if !m.recovering {
+				if errors.Is(chunkErr, ErrTruncated) {
+					return ErrTruncated
+				}
+				return chunkErr
+			}
+			m.recovered = append(m.recovered, chunkErr)
+
+			// The previous length may have been too long as well as too
+			// short, so look for the chunk from the start of the
+			// previous track's data.
+			from := int64(14)
+			if i > 0 {
+				from = m.trackOffsets[i-1]
+			}
+			next := bytes.Index(b[from:], []byte("MTrk"))
+			if next < 0 || from+int64(next)+8 > int64(len(b)) {
+				m.dropTracks(i)
+				break
+			}
+			found := from + int64(next)
+			if i > 0 {
+				m.trackLengths[i-1] = found - m.trackOffsets[i-1]
+			}
+			bitIndex = found
 		}
 		bitIndex += 4
 
@@ -157,6 +380,25 @@ func (m *MIDIFile) parseRawData() error {
 		binary.Read(bytes.NewReader(b[bitIndex:bitIndex+4]),
 			binary.BigEndian, &length)
 		bitIndex += 4
+		if (length < 0 || bitIndex+int64(length) > int64(len(b))) && m.recovering {
+			available := int64(len(b)) - bitIndex
+			if next := bytes.Index(b[bitIndex:], []byte("MTrk")); next >= 0 {
+				available = int64(next)
+			}
+			m.recovered = append(m.recovered, fmt.Errorf(
+				"track %d: length %d exceeds the file, using %d: %w",
+				i, length, available, ErrTruncated))
+			length = int32(available)
+		}
+		if length < 0 || bitIndex+int64(length) > int64(len(b)) {
+			if !m.options.Lenient {
+				return ErrTruncated
+			}
+			available := int32(int64(len(b)) - bitIndex)
+			m.warn(i, bitIndex-4, fmt.Sprintf(
+				"track length %d exceeds the file, using %d", length, available))
+			length = available
+		}
 
 		m.trackLengths[i] = int64(length)
 		m.trackOffsets[i] = int64(bitIndex)
@@ -172,16 +414,31 @@ func (m *MIDIFile) parseRawData() error {
 		bitIndex += int64(length)
 	}
 
+	// Anything after the last track chunk, such as padding added by
+	// exporters, is ignored.
+	m.tracksEnd = bitIndex
+
 	// Save the initial tickSeconds parameter.
 	tempoEvent := TempoChange{
-		Count:       0,
-		TickSeconds: m.tickSeconds[0],
+		Count:            0,
+		TickSeconds:      float64(0.5 / tickrate),
+		MicrosPerQuarter: defaultMicrosPerQuarter,
+	}
+	if m.UsingTimeCode {
+		tempoEvent.TickSeconds = float64(1.0 / tickrate)
 	}
 	m.tempoEvents = append(m.tempoEvents, tempoEvent)
 
-	// If format 1 and not using time code, parse and save the tempo map
-	// on track 0.
-	if m.Format == 1 && !m.UsingTimeCode {
+	// Decode every event once up front so that malformed tracks are
+	// reported here rather than by a panic in NextEvent.
+	if err := m.validateTracks(); err != nil {
+		return err
+	}
+
+	// If not using time code, parse and save the tempo map on track 0:
+	// the conductor track in format 1, or the only track in format 0.
+	// Format 2 tracks are independent, so there is no global map.
+	if m.Format != 2 && !m.UsingTimeCode && m.NumTracks > 0 {
 		var count uint64
 		var event []byte
 
@@ -198,7 +455,8 @@ func (m *MIDIFile) parseRawData() error {
 			if len(event) == 6 && event[0] == 0xFF &&
 				event[1] == 0x51 && event[2] == 0x03 {
 				tempoEvent.Count = count
-				value := event[3]<<16 + event[4]<<8 + event[5]
+				value := tempoValue(event)
+				tempoEvent.MicrosPerQuarter = value
 				tempoEvent.TickSeconds = float64(0.000001 *
 					float64(value) / tickrate)
 				tail := len(m.tempoEvents) - 1
@@ -227,59 +485,244 @@ func (m *MIDIFile) parseRawData() error {
 	return nil
 }
 
+// validateTracks decodes all events of every wanted track, and of the
+// first track that holds the tempo map, returning the first error found.
+// It leaves every track rewound.
+func (m *MIDIFile) validateTracks() error {
+	for _, track := range m.options.Tracks {
+		if track < 0 || track >= m.NumTracks {
+			return fmt.Errorf("invalid track %d", track)
+		}
+	}
+
+	var buf []byte
+	for i := 0; i < m.NumTracks; i++ {
+		if !m.validated(i) {
+			continue
+		}
+		end := m.trackOffsets[i] + m.trackLengths[i]
+		ended := false
+		for m.trackPointers[i] < end {
+			if ended {
+				if !m.options.Lenient && !m.recovering {
+					return m.rewindOnError(i, fmt.Errorf("track %d: %w at offset %d",
+						i, ErrDataAfterEndOfTrack, m.trackPointers[i]))
+				}
+				m.warn(i, m.trackPointers[i], "data after end of track")
+			}
+			_, event, next, status, err := m.readEvent(i, buf[:0])
+			if err != nil && m.recovering {
+				m.recovered = append(m.recovered, fmt.Errorf(
+					"track %d: cut at offset %d: %w", i, m.trackPointers[i], err))
+				m.trackLengths[i] = m.trackPointers[i] - m.trackOffsets[i]
+				end = m.trackPointers[i]
+				break
+			}
+			if err != nil {
+				return m.rewindOnError(i, fmt.Errorf("track %d: %w", i, err))
+			}
+			buf = event
+			ended = isEndOfTrack(event)
+			m.trackPointers[i] = next
+			m.trackStatus[i] = status
+		}
+		if !ended {
+			if !m.options.Lenient && !m.recovering {
+				return m.rewindOnError(i, fmt.Errorf("track %d: %w",
+					i, ErrMissingEndOfTrack))
+			}
+			m.warn(i, end, "missing end of track")
+		}
+		m.trackPointers[i] = m.trackOffsets[i]
+		m.trackStatus[i] = 0
+	}
+	return nil
+}
+
+// rewindOnError rewinds track after a failed validation and returns err.
+func (m *MIDIFile) rewindOnError(track int, err error) error {
+	m.trackPointers[track] = m.trackOffsets[track]
+	m.trackStatus[track] = 0
+	return err
+}
+
+// dropTracks reduces the file to its first n tracks, for ReadRecover.
+func (m *MIDIFile) dropTracks(n int) {
+	m.NumTracks = n
+	m.tickSeconds = m.tickSeconds[:n]
+	m.trackPointers = m.trackPointers[:n]
+	m.trackOffsets = m.trackOffsets[:n]
+	m.trackLengths = m.trackLengths[:n]
+	m.trackStatus = m.trackStatus[:n]
+}
+
+// wantTrack reports whether the events of track are to be read, as set
+// by ReadOptions.Tracks.
+func (m *MIDIFile) wantTrack(track int) bool {
+	if len(m.options.Tracks) == 0 {
+		return true
+	}
+	for _, t := range m.options.Tracks {
+		if t == track {
+			return true
+		}
+	}
+	return false
+}
+
+// validated reports whether the events of track were checked when the
+// file was read: the wanted tracks and, in format 0 and 1, the first
+// track that holds the tempo map.
+func (m *MIDIFile) validated(track int) bool {
+	return m.wantTrack(track) || track == 0 && m.Format != 2
+}
+
+// FormatRepaired reports whether the file claimed format 0 but had
+// several tracks and was read as format 1 because of
+// ReadOptions.RepairFormat.
+func (m *MIDIFile) FormatRepaired() bool {
+	return m.formatRepaired
+}
+
+// TempoChanges returns the tempo map read from the first track of a
+// format 0 or 1 file, starting with the tempo in effect at tick 0. For
+// format 2 and time-code based files it only holds the initial tempo.
+func (m *MIDIFile) TempoChanges() []TempoChange {
+	return m.tempoEvents
+}
+
+// TickToSeconds converts an absolute tick to seconds using the tempo map.
+func (m *MIDIFile) TickToSeconds(tick uint64) float64 {
+	return tickToSeconds(m.tempoEvents, int64(tick))
+}
+
+// SMPTEFormat returns the frame rate of a time-code based division and
+// whether it is drop frame: 24, 25, 29.97 (30 fps drop frame) or 30.
+// For metrical division it returns 0 and false.
+func (m *MIDIFile) SMPTEFormat() (fps float64, dropFrame bool) {
+	return smpteFormat(m.Division)
+}
+
 func (m *MIDIFile) NextEvent(track int) (uint64, []byte) {
 	if track >= m.NumTracks {
 		panic("invalid track number")
 	}
 
-	var event []byte
+	// Tracks left out by ReadOptions.Tracks weren't checked and may not
+	// decode.
+	if !m.validated(track) ||
+		m.trackPointers[track]-m.trackOffsets[track] >= m.trackLengths[track] {
+		return 0, nil
+	}
+
+	ticks, event, next, status, err := m.readEvent(track, nil)
+	if err != nil {
+		panic(err)
+	}
+	m.advance(track, ticks, event, next, status)
+
+	return ticks, event
+}
+
+// NextEventInto is like NextEvent but writes the event bytes into buf
+// instead of allocating a new slice, so that a single buffer can be reused
+// across calls. It returns the delta time and the number of bytes written.
+// At the end of the track it returns io.EOF. If buf is too small to hold
+// the event, it returns io.ErrShortBuffer without consuming the event.
+func (m *MIDIFile) NextEventInto(track int, buf []byte) (uint64, int, error) {
+	if track < 0 || track >= m.NumTracks {
+		return 0, 0, errors.New("invalid track number")
+	}
 
 	if m.trackPointers[track]-m.trackOffsets[track] >= m.trackLengths[track] {
-		return 0, nil
+		return 0, 0, io.EOF
+	}
+
+	ticks, event, next, status, err := m.readEvent(track, buf[:0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(event) > len(buf) {
+		return 0, 0, io.ErrShortBuffer
 	}
+	m.advance(track, ticks, event, next, status)
+
+	return ticks, len(event), nil
+}
 
+// readEvent decodes the event at the current position of track and
+// appends its bytes to event. It doesn't move the track; the position of
+// the following event and the running status after this event are
+// returned so that the caller can commit them with advance. Repairs made
+// to the event are recorded as warnings.
+func (m *MIDIFile) readEvent(track int, event []byte) (uint64, []byte,
+	int64, byte, error) {
 	var ticks, b uint64
 	var position uint64
-	isTempoEvent := false
+	var channel bool
+	status := m.trackStatus[track]
+	end := m.trackOffsets[track] + m.trackLengths[track]
 
 	// Read the event delta time.
-	bitIndex, err := m.readVariableLength(&ticks, m.trackPointers[track])
+	bitIndex, err := m.readVariableLength(&ticks, m.trackPointers[track], end)
 	if err != nil {
-		panic(err)
+		return 0, nil, 0, 0, err
 	}
 
 	// Parse the event stream to determine the event length.
+	if bitIndex >= end {
+		return 0, nil, 0, 0, ErrTruncated
+	}
 	c := m.rawData[bitIndex : bitIndex+1][0]
 	bitIndex += 1
 
+	if c&0x80 == 0 && status&0x80 == 0 {
+		if !m.options.Lenient {
+			return 0, nil, 0, 0, fmt.Errorf(
+				"%w: data byte 0x%02X without status", ErrBadEvent, c)
+		}
+		start := bitIndex - 1
+		for bitIndex < end && m.rawData[bitIndex]&0x80 == 0 {
+			bitIndex++
+		}
+		if bitIndex >= end {
+			return 0, nil, 0, 0, ErrTruncated
+		}
+		m.warn(track, start, fmt.Sprintf(
+			"running status without prior status, skipped %d data bytes",
+			bitIndex-start))
+		c = m.rawData[bitIndex]
+		bitIndex++
+	}
+
 	switch c {
 	case 0xFF: // A Meta-Event
-		m.trackStatus[track] = 0
+		status = 0
 		event = append(event, c)
+		if bitIndex >= end {
+			return 0, nil, 0, 0, ErrTruncated
+		}
 		c = m.rawData[bitIndex : bitIndex+1][0]
 		bitIndex += 1
 		event = append(event, c)
-		if m.Format != 1 && c == 0x51 {
-			isTempoEvent = true
-		}
 		position = uint64(bitIndex)
 
-		bitIndex, err := m.readVariableLength(&b, bitIndex)
+		bitIndex, err := m.readVariableLength(&b, bitIndex, end)
 		if err != nil {
-			panic(err)
+			return 0, nil, 0, 0, err
 		}
 		b += uint64(uint64(bitIndex) - position)
 		bitIndex = int64(position)
 
 	// The start or continuation of a Sysex event
-	case 0xF0 | 0xF1 | 0xF2 | 0xF3 | 0xF4 | 0xF5 | 0xF6 | 0xF7:
-		m.trackStatus[track] = 0
+	case 0xF0, 0xF7:
+		status = 0
 		event = append(event, c)
 		position = uint64(bitIndex)
 
-		bitIndex, err := m.readVariableLength(&b, bitIndex)
+		bitIndex, err := m.readVariableLength(&b, bitIndex, end)
 		if err != nil {
-			panic(err)
+			return 0, nil, 0, 0, err
 		}
 		b += uint64(uint64(bitIndex) - position)
 		bitIndex = int64(position)
@@ -288,9 +731,11 @@ func (m *MIDIFile) NextEvent(track int) (uint64, []byte) {
 	default:
 		if c&0x80 > 0 {
 			if c > 0xF0 {
-				panic("invlid midi channel event")
+				return 0, nil, 0, 0, fmt.Errorf("%w: status 0x%02X",
+					ErrBadEvent, c)
 			}
-			m.trackStatus[track] = c
+			status = c
+			channel = true
 			event = append(event, c)
 			c &= 0xF0
 			if c == 0xC0 || c == 0xD0 {
@@ -298,30 +743,74 @@ func (m *MIDIFile) NextEvent(track int) (uint64, []byte) {
 			} else {
 				b = 2
 			}
-		} else if m.trackStatus[track]&0x80 == 1 {
-			event = append(event, m.trackStatus[track])
+		} else {
+			channel = true
+			event = append(event, status)
 			event = append(event, c)
-			c = m.trackStatus[track] & 0xF0
+			c = status & 0xF0
 			if c != 0xC0 && c != 0xD0 {
 				b = 1
 			}
-		} else {
-			panic("invalid midi channel event; never reach here.")
 		}
 	}
 
 	// Read the rest of the event into the event vector.
+	if b > uint64(end-bitIndex) {
+		return 0, nil, 0, 0, ErrTruncated
+	}
 	for i := 0; i < int(b); i++ {
 		c := m.rawData[bitIndex : bitIndex+1][0]
+		if channel && c&0x80 != 0 {
+			if m.options.StrictDataBytes {
+				return 0, nil, 0, 0, fmt.Errorf(
+					"%w: data byte 0x%02X at offset %d", ErrBadEvent,
+					c, bitIndex)
+			}
+			m.warn(track, bitIndex, fmt.Sprintf(
+				"data byte 0x%02X masked to 0x%02X", c, c&0x7F))
+			c &= 0x7F
+		}
 		bitIndex += 1
 		event = append(event, c)
 	}
 
+	return ticks, event, bitIndex, status, nil
+}
+
+// warn records a warning for the byte at offset, unless one was already
+// recorded there when the track was read before.
+func (m *MIDIFile) warn(track int, offset int64, message string) {
+	if m.warned == nil {
+		m.warned = make(map[int64]bool)
+	}
+	if m.warned[offset] {
+		return
+	}
+	m.warned[offset] = true
+	m.warnings = append(m.warnings, Warning{
+		Track:   track,
+		Offset:  offset,
+		Message: message,
+	})
+}
+
+// Warnings returns the problems that were repaired or tolerated while
+// reading the file, in the order they were found, such as masked data
+// bytes and the repairs made by ReadOptions.Lenient, e.g. of a missing
+// end of track. An empty result means the file is well formed.
+func (m *MIDIFile) Warnings() []Warning {
+	return m.warnings
+}
+
+// advance commits an event returned by readEvent: it moves the track
+// pointer, saves the running status and updates the tempo bookkeeping.
+func (m *MIDIFile) advance(track int, ticks uint64, event []byte,
+	next int64, status byte) {
 	if !m.UsingTimeCode {
-		if isTempoEvent {
+		if m.Format != 1 && isTempoEvent(event) {
 			// Parse the tempo event and update tickSeconds_[track].
 			tickrate := float64(m.Division & 0x7FFF)
-			value := event[3]<<16 + event[4]<<8 + event[5]
+			value := tempoValue(event)
 			m.tickSeconds[track] = float64(0.000001 * float64(value) /
 				tickrate)
 		}
@@ -337,10 +826,9 @@ func (m *MIDIFile) NextEvent(track int) (uint64, []byte) {
 		}
 	}
 
-	// Save the current track pointer value.
-	m.trackPointers[track] = bitIndex
-
-	return ticks, event
+	// Save the current track pointer value and running status.
+	m.trackPointers[track] = next
+	m.trackStatus[track] = status
 }
 
 func (m *MIDIFile) NextMIDIEvent(track int) (uint64, []byte) {
@@ -368,6 +856,107 @@ func (m *MIDIFile) RewindTrack(track int) {
 	m.trackPointers[track] = m.trackOffsets[track]
 	m.trackStatus[track] = 0
 	m.tickSeconds[track] = m.tempoEvents[0].TickSeconds
+	if m.trackCounters != nil {
+		m.trackCounters[track] = 0
+		m.trackTempoIndex[track] = 0
+	}
+}
+
+// TrackBytes returns the raw payload of an MTrk chunk, excluding the chunk
+// header. The returned slice aliases the file data, so it must not be
+// modified.
+func (m *MIDIFile) TrackBytes(track int) []byte {
+	if track < 0 || track >= m.NumTracks {
+		panic("invalid track argmnent")
+	}
+
+	start := m.trackOffsets[track]
+	end := start + m.trackLengths[track]
+	if end > int64(len(m.rawData)) {
+		end = int64(len(m.rawData))
+	}
+	return m.rawData[start:end:end]
+}
+
+// TrailingBytes returns the bytes following the last track chunk, or nil
+// if there are none. The returned slice aliases the file data.
+func (m *MIDIFile) TrailingBytes() []byte {
+	if m.tracksEnd >= int64(len(m.rawData)) {
+		return nil
+	}
+	return m.rawData[m.tracksEnd:]
+}
+
+// Chunk is a top-level chunk of a MIDI file.
+type Chunk struct {
+	Type string // four ASCII characters, e.g. "MTrk"
+	Data []byte
+}
+
+// validChunkType reports whether typ is four printable ASCII characters.
+func validChunkType(typ string) bool {
+	if len(typ) != 4 {
+		return false
+	}
+	for i := 0; i < len(typ); i++ {
+		if typ[i] < 0x20 || typ[i] > 0x7E {
+			return false
+		}
+	}
+	return true
+}
+
+// UnknownChunks returns the chunks following the last track chunk, such
+// as vendor specific data, which readers are expected to skip. Parsing
+// stops at the first bytes that don't form a complete chunk with a
+// printable type, so padding is not reported. The data aliases the file.
+func (m *MIDIFile) UnknownChunks() []Chunk {
+	var chunks []Chunk
+	b := m.TrailingBytes()
+	for len(b) >= 8 {
+		typ := string(b[0:4])
+		length := int64(binary.BigEndian.Uint32(b[4:8]))
+		if !validChunkType(typ) || length > int64(len(b)-8) {
+			break
+		}
+		chunks = append(chunks, Chunk{
+			Type: typ,
+			Data: b[8 : 8+length : 8+length],
+		})
+		b = b[8+length:]
+	}
+	return chunks
+}
+
+// imageMagics are the signatures of the image formats EmbeddedImages
+// recognizes: JPEG, PNG and GIF.
+var imageMagics = [][]byte{
+	{0xFF, 0xD8, 0xFF},
+	{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A},
+	[]byte("GIF87a"),
+	[]byte("GIF89a"),
+}
+
+// EmbeddedImages returns the images, such as album art in karaoke files,
+// found in the UnknownChunks of the file. A chunk holds an image if its
+// data contains the signature of a JPEG, PNG or GIF file, possibly after
+// a vendor header; the image is taken to run from the signature to the
+// end of the chunk. The data aliases the file. It returns nil if there are
+// no images.
+func (m *MIDIFile) EmbeddedImages() [][]byte {
+	var images [][]byte
+	for _, c := range m.UnknownChunks() {
+		start := -1
+		for _, magic := range imageMagics {
+			if i := bytes.Index(c.Data, magic); i >= 0 && (start < 0 || i < start) {
+				start = i
+			}
+		}
+		if start >= 0 {
+			images = append(images, c.Data[start:])
+		}
+	}
+	return images
 }
 
 func (m *MIDIFile) TickSeconds(track int) float64 {
@@ -378,17 +967,24 @@ func (m *MIDIFile) TickSeconds(track int) float64 {
 	return m.tickSeconds[track]
 }
 
-func (m *MIDIFile) readVariableLength(val *uint64, bitIndex int64) (int64, error) {
+func (m *MIDIFile) readVariableLength(val *uint64, bitIndex, end int64) (int64, error) {
 	*val = 0
+	if bitIndex >= end {
+		return 0, ErrTruncated
+	}
 	c := m.rawData[bitIndex : bitIndex+1][0]
 	*val = uint64(c)
 	bitIndex += 1
 
 	if *val&0x80 > 0 {
 		*val &= 0x7F
-		for {
-			if bitIndex >= int64(len(m.rawData)) {
-				return 0, errors.New("error")
+		for n := 1; ; n++ {
+			if bitIndex >= end {
+				return 0, ErrTruncated
+			}
+			if n == 4 {
+				return 0, fmt.Errorf("%w: variable-length quantity longer than 4 bytes",
+					ErrBadEvent)
 			}
 			c = m.rawData[bitIndex : bitIndex+1][0]
 			bitIndex += 1
@@ -1,10 +1,48 @@
 package midi
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
 	"testing"
 )
 
+// buildSMF assembles a standard MIDI file from raw MTrk payloads.
+func buildSMF(format, division int, tracks ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("MThd")
+	binary.Write(&buf, binary.BigEndian, uint32(6))
+	binary.Write(&buf, binary.BigEndian, uint16(format))
+	binary.Write(&buf, binary.BigEndian, uint16(len(tracks)))
+	binary.Write(&buf, binary.BigEndian, uint16(division))
+	for _, track := range tracks {
+		buf.WriteString("MTrk")
+		binary.Write(&buf, binary.BigEndian, uint32(len(track)))
+		buf.Write(track)
+	}
+	return buf.Bytes()
+}
+
+// endOfTrack is the end of track meta event with a zero delta time.
+var endOfTrack = []byte{0x00, 0xFF, 0x2F, 0x00}
+
+// largeSMF returns a format 0 file with n notes in a single track.
+func largeSMF(n int) []byte {
+	var track []byte
+	for i := 0; i < n; i++ {
+		key := byte(36 + i%48)
+		track = append(track, 0x00, 0x90, key, 0x64, 0x60, 0x80, key, 0x00)
+	}
+	track = append(track, endOfTrack...)
+	return buildSMF(0, 480, track)
+}
+
 func TestMIDIFileReader(t *testing.T) {
 	m, err := ReadMIDI("test.mid")
 	if err != nil {
@@ -44,3 +82,676 @@ func TestMIDIData(t *testing.T) {
 	track := data.At(0)
 	fmt.Println(track.At(0))
 }
+
+func TestNextEventInto(t *testing.T) {
+	m, err := ReadMIDI("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	track := 1
+	var events [][]byte
+	var deltas []uint64
+	for {
+		delta, event := m.NextEvent(track)
+		if event == nil {
+			break
+		}
+		deltas = append(deltas, delta)
+		events = append(events, event)
+	}
+
+	m.RewindTrack(track)
+	for i := 0; ; i++ {
+		delta, n, err := m.NextEventInto(track, buf)
+		if err == io.EOF {
+			if i != len(events) {
+				t.Errorf("got %d events, want %d", i, len(events))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delta != deltas[i] || !bytes.Equal(buf[:n], events[i]) {
+			t.Errorf("event %d: got %d % X, want %d % X",
+				i, delta, buf[:n], deltas[i], events[i])
+		}
+	}
+}
+
+func TestNextEventIntoShortBuffer(t *testing.T) {
+	m, err := Read(bytes.NewReader(buildSMF(0, 480,
+		append([]byte{0x00, 0xFF, 0x03, 0x04, 'a', 'b', 'c', 'd'}, endOfTrack...))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := m.NextEventInto(0, make([]byte, 4)); err != io.ErrShortBuffer {
+		t.Fatalf("err = %v, want io.ErrShortBuffer", err)
+	}
+	_, n, err := m.NextEventInto(0, make([]byte, 8))
+	if err != nil || n != 7 {
+		t.Errorf("n, err = %d, %v; want 7, nil", n, err)
+	}
+}
+
+func BenchmarkNextEvent(b *testing.B) {
+	m, err := Read(bytes.NewReader(largeSMF(10000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.RewindTrack(0)
+		for {
+			_, event := m.NextEvent(0)
+			if event == nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkNextEventInto(b *testing.B) {
+	m, err := Read(bytes.NewReader(largeSMF(10000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 256)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.RewindTrack(0)
+		for {
+			if _, _, err := m.NextEventInto(0, buf); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestTrailingBytes(t *testing.T) {
+	track := []byte{0x00, 0x90, 60, 100, 0x60, 0x80, 60, 0}
+	track = append(track, endOfTrack...)
+	junk := []byte{0x00, 0x00, 0xDE, 0xAD, 'M', 'T', 'r', 'k'}
+	data := append(buildSMF(0, 480, track), junk...)
+
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.TrailingBytes(), junk) {
+		t.Errorf("TrailingBytes() = % X, want % X", m.TrailingBytes(), junk)
+	}
+
+	d := BuildMIDIDataFromMIDIFile(m)
+	if d.Len() != 1 || d.At(0).Len() != 3 {
+		t.Errorf("trailing bytes leaked into the parsed events")
+	}
+
+	m, err = ReadMIDI("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TrailingBytes() != nil {
+		t.Errorf("TrailingBytes() = % X, want nil", m.TrailingBytes())
+	}
+}
+
+func TestTrackBytes(t *testing.T) {
+	first := []byte{0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20, 0x00, 0xFF, 0x2F, 0x00}
+	second := []byte{0x00, 0x90, 60, 100, 0x60, 0x80, 60, 0, 0x00, 0xFF, 0x2F, 0x00}
+
+	m, err := Read(bytes.NewReader(buildSMF(1, 480, first, second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(m.TrackBytes(0), first) {
+		t.Errorf("TrackBytes(0) = % X, want % X", m.TrackBytes(0), first)
+	}
+	if !bytes.Equal(m.TrackBytes(1), second) {
+		t.Errorf("TrackBytes(1) = % X, want % X", m.TrackBytes(1), second)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	valid := buildSMF(1, 480, endOfTrack)
+	withHeader := func(offset int, b ...byte) []byte {
+		data := append([]byte(nil), valid...)
+		copy(data[offset:], b)
+		return data
+	}
+
+	cases := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrNotMIDI},
+		{"riff", []byte("RIFF\x00\x00\x00\x04RMID"), ErrNotMIDI},
+		{"short header", valid[:10], ErrTruncated},
+		{"header length", withHeader(4, 0, 0, 0, 7), ErrBadHeaderLength},
+		{"format", withHeader(8, 0, 3), ErrUnknownFormat},
+		{"format 0 tracks", withHeader(8, 0, 0, 0, 2), ErrBadTrackCount},
+		{"division 0", withHeader(12, 0, 0), ErrBadDivision},
+		{"frame rate", withHeader(12, 0xE0, 40), ErrBadDivision},
+		{"missing track", valid[:16], ErrTruncated},
+		{"short track", valid[:len(valid)-1], ErrTruncated},
+	}
+	for _, c := range cases {
+		_, err := Read(bytes.NewReader(c.data))
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: err = %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	_, err := Read(bytes.NewReader(withHeader(14, 'M', 'T', 'r', 'x')))
+	var chunkErr *BadChunkError
+	if !errors.As(err, &chunkErr) {
+		t.Fatalf("err = %v, want *BadChunkError", err)
+	}
+	if chunkErr.Type != "MTrx" || chunkErr.Offset != 14 {
+		t.Errorf("got %+v, want type MTrx at offset 14", chunkErr)
+	}
+}
+
+func TestDivision(t *testing.T) {
+	for _, division := range []int{1, 96, 480, 960, 0x7FFF} {
+		m, err := Read(bytes.NewReader(buildSMF(0, division, endOfTrack)))
+		if err != nil {
+			t.Errorf("division %d: %v", division, err)
+			continue
+		}
+		if m.Division != division {
+			t.Errorf("Division = %d, want %d", m.Division, division)
+		}
+		want := 0.5 / float64(division)
+		if got := m.TickSeconds(0); got != want {
+			t.Errorf("division %d: TickSeconds = %g, want %g", division, got, want)
+		}
+	}
+
+	_, err := Read(bytes.NewReader(buildSMF(0, 0, endOfTrack)))
+	if err == nil || err.Error() != "invalid division 0" {
+		t.Errorf("err = %v, want invalid division 0", err)
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	file, err := os.Open("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	format, numTracks, division, err := ReadHeader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != 1 || numTracks != 2 || division != 960 {
+		t.Errorf("ReadHeader = %d, %d, %d; want 1, 2, 960",
+			format, numTracks, division)
+	}
+
+	if _, _, _, err := ReadHeader(bytes.NewReader([]byte("MThd\x00"))); !errors.Is(err, ErrTruncated) {
+		t.Errorf("err = %v, want ErrTruncated", err)
+	}
+	if _, _, _, err := ReadHeader(bytes.NewReader([]byte("RIFF"))); !errors.Is(err, ErrNotMIDI) {
+		t.Errorf("err = %v, want ErrNotMIDI", err)
+	}
+}
+
+func TestRunningStatus(t *testing.T) {
+	track := []byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x3C, 0x00}
+	track = append(track, endOfTrack...)
+	m, err := Read(bytes.NewReader(buildSMF(0, 480, track)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.NextEvent(0)
+	ticks, event := m.NextEvent(0)
+	if ticks != 0x60 || !bytes.Equal(event, []byte{0x90, 0x3C, 0x00}) {
+		t.Errorf("NextEvent() = %d % X, want 96 90 3C 00", ticks, event)
+	}
+}
+
+func TestStrictDataBytes(t *testing.T) {
+	track := []byte{0x00, 0x90, 0x3C, 0xE4}
+	track = append(track, endOfTrack...)
+	data := buildSMF(0, 480, track)
+
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, event := m.NextEvent(0); !bytes.Equal(event, []byte{0x90, 0x3C, 0x64}) {
+		t.Errorf("NextEvent() = % X, want 90 3C 64", event)
+	}
+	m.RewindTrack(0)
+	m.NextEvent(0)
+	want := []Warning{{Track: 0, Offset: 25, Message: "data byte 0xE4 masked to 0x64"}}
+	if got := m.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+
+	_, err = ReadWithOptions(bytes.NewReader(data),
+		ReadOptions{StrictDataBytes: true})
+	if !errors.Is(err, ErrBadEvent) {
+		t.Errorf("ReadWithOptions() error = %v, want ErrBadEvent", err)
+	}
+}
+
+func FuzzReadWrite(f *testing.F) {
+	f.Add(largeSMF(4))
+	f.Add(buildSMF(1, 96))
+	f.Add(buildSMF(1, 96,
+		append([]byte{0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20}, endOfTrack...),
+		append([]byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x3C, 0x00}, endOfTrack...)))
+	f.Add(buildSMF(0, 480,
+		append([]byte{0x00, 0xF0, 0x03, 0x7E, 0x7F, 0xF7}, endOfTrack...)))
+	if data, err := ioutil.ReadFile("test.mid"); err == nil {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := Read(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		d := BuildMIDIDataFromMIDIFile(m)
+
+		var first bytes.Buffer
+		if err := Write(&first, d, WriteOptions{}); err != nil {
+			return
+		}
+		m, err = Read(bytes.NewReader(first.Bytes()))
+		if err != nil {
+			t.Fatalf("reading written data: %v", err)
+		}
+		var second bytes.Buffer
+		if err := Write(&second, BuildMIDIDataFromMIDIFile(m), WriteOptions{}); err != nil {
+			t.Fatalf("writing again: %v", err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("round trip not stable:\n% X\n% X",
+				first.Bytes(), second.Bytes())
+		}
+	})
+}
+
+func TestSMPTEFormat(t *testing.T) {
+	tests := []struct {
+		division  int
+		fps       float64
+		dropFrame bool
+	}{
+		{0xE850, 24, false}, // -24, 80 ticks per frame
+		{0xE728, 25, false},
+		{0xE328, 30000.0 / 1001.0, true},
+		{0xE250, 30, false},
+	}
+	for _, tt := range tests {
+		m, err := Read(bytes.NewReader(buildSMF(0, tt.division, endOfTrack)))
+		if err != nil {
+			t.Errorf("division %#x: %v", tt.division, err)
+			continue
+		}
+		fps, drop := m.SMPTEFormat()
+		if fps != tt.fps || drop != tt.dropFrame {
+			t.Errorf("division %#x: SMPTEFormat() = %g, %v, want %g, %v",
+				tt.division, fps, drop, tt.fps, tt.dropFrame)
+		}
+		want := 1 / (tt.fps * float64(tt.division&0xFF))
+		if got := m.TickSeconds(0); math.Abs(got-want) > 1e-12 {
+			t.Errorf("division %#x: TickSeconds = %g, want %g",
+				tt.division, got, want)
+		}
+	}
+
+	m, err := Read(bytes.NewReader(buildSMF(0, 480, endOfTrack)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fps, drop := m.SMPTEFormat(); fps != 0 || drop {
+		t.Errorf("SMPTEFormat() = %g, %v for metrical division", fps, drop)
+	}
+
+	_, err = Read(bytes.NewReader(buildSMF(0, 0xE700, endOfTrack)))
+	if !errors.Is(err, ErrBadDivision) {
+		t.Errorf("err = %v, want ErrBadDivision", err)
+	}
+
+	// An unknown frame rate is an error unless Lenient reads it as 30.
+	data := buildSMF(0, 0xE028, endOfTrack)
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrBadDivision) {
+		t.Errorf("frame rate 32: err = %v, want ErrBadDivision", err)
+	}
+	m, err = ReadWithOptions(bytes.NewReader(data), ReadOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fps, _ := m.SMPTEFormat(); fps != 30 || m.Division&0xFF != 40 {
+		t.Errorf("Lenient: SMPTEFormat() = %g, Division = %#x, want 30 fps, 40 ticks per frame",
+			fps, m.Division)
+	}
+	if want := 1.0 / 1200; math.Abs(m.TickSeconds(0)-want) > 1e-12 {
+		t.Errorf("Lenient: TickSeconds = %g, want %g", m.TickSeconds(0), want)
+	}
+	if w := m.Warnings(); len(w) != 1 || w[0].Message != "unknown frame rate 32, assuming 30" {
+		t.Errorf("Lenient: Warnings() = %v, want the frame rate", w)
+	}
+}
+
+func TestFormat0TempoMap(t *testing.T) {
+	track := []byte{
+		0x00, 0x90, 0x3C, 0x64,
+		0x83, 0x60, 0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40, // 60 bpm at 480
+		0x83, 0x60, 0x80, 0x3C, 0x00,
+	}
+	track = append(track, endOfTrack...)
+	m, err := Read(bytes.NewReader(buildSMF(0, 480, track)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tempo := m.TempoChanges()
+	if len(tempo) != 2 || tempo[1].Count != 480 ||
+		tempo[1].MicrosPerQuarter != 1000000 {
+		t.Fatalf("TempoChanges() = %+v, want a change to 1000000 at 480", tempo)
+	}
+	if got := m.TickToSeconds(960); math.Abs(got-1.5) > 1e-9 {
+		t.Errorf("TickToSeconds(960) = %g, want 1.5", got)
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	data := largeSMF(100)
+	n := int64(len(data))
+
+	if _, err := ReadLimited(bytes.NewReader(data), n); err != nil {
+		t.Errorf("ReadLimited(%d) = %v", n, err)
+	}
+	if _, err := ReadLimited(bytes.NewReader(data), n-1); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("ReadLimited(%d) error = %v, want ErrTooLarge", n-1, err)
+	}
+	if _, err := ReadLimited(bytes.NewReader(data), math.MaxInt64); err != nil {
+		t.Errorf("ReadLimited(math.MaxInt64) = %v", err)
+	}
+
+	opts := ReadOptions{MaxBytes: n - 1, Lenient: true}
+	if _, err := ReadWithOptions(bytes.NewReader(data), opts); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("MaxBytes %d: error = %v, want ErrTooLarge", n-1, err)
+	}
+	opts.MaxBytes = n
+	if _, err := ReadWithOptions(bytes.NewReader(data), opts); err != nil {
+		t.Errorf("MaxBytes %d: %v", n, err)
+	}
+}
+
+func TestLenientHeaderLength(t *testing.T) {
+	data := buildSMF(0, 480, endOfTrack)
+	binary.BigEndian.PutUint32(data[4:8], 8)
+
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrBadHeaderLength) {
+		t.Errorf("Read() error = %v, want ErrBadHeaderLength", err)
+	}
+
+	m, err := ReadWithOptions(bytes.NewReader(data), ReadOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NumTracks != 1 || m.Division != 480 {
+		t.Errorf("NumTracks, Division = %d, %d, want 1, 480", m.NumTracks, m.Division)
+	}
+	want := []Warning{{Track: -1, Offset: 4, Message: "header length 8, assuming 6"}}
+	if got := m.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+}
+
+func TestRepairFormat(t *testing.T) {
+	track := append([]byte{0x00, 0x90, 0x3C, 0x64}, endOfTrack...)
+	data := buildSMF(0, 480, endOfTrack, track)
+
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrBadTrackCount) {
+		t.Errorf("Read() error = %v, want ErrBadTrackCount", err)
+	}
+
+	m, err := ReadWithOptions(bytes.NewReader(data), ReadOptions{RepairFormat: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Format != 1 || m.NumTracks != 2 || !m.FormatRepaired() {
+		t.Errorf("Format, NumTracks, FormatRepaired() = %d, %d, %v, want 1, 2, true",
+			m.Format, m.NumTracks, m.FormatRepaired())
+	}
+	if len(m.Warnings()) != 1 {
+		t.Errorf("Warnings() = %v, want one warning", m.Warnings())
+	}
+
+	m, err = ReadWithOptions(bytes.NewReader(buildSMF(0, 480, track)),
+		ReadOptions{RepairFormat: true})
+	if err != nil || m.Format != 0 || m.FormatRepaired() {
+		t.Errorf("single track file: Format = %d, FormatRepaired() = %v, err = %v",
+			m.Format, m.FormatRepaired(), err)
+	}
+}
+
+func TestReadTracks(t *testing.T) {
+	conductor := append([]byte{
+		0x00, 0xFF, 0x03, 0x01, 'C',
+		0x00, 0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40, // 60 bpm
+		0x00, 0xFF, 0x58, 0x04, 3, 2, 24, 8,
+	}, endOfTrack...)
+	broken := []byte{0x00, 0xFF, 0x51, 0x09}
+	notes := append([]byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x80, 0x3C, 0x00}, endOfTrack...)
+	data := buildSMF(1, 96, conductor, broken, notes)
+
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("Read() should fail on the broken track")
+	}
+
+	m, err := ReadWithOptions(bytes.NewReader(data), ReadOptions{Tracks: []int{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.TempoChanges(); len(got) != 1 || got[0].MicrosPerQuarter != 1000000 {
+		t.Errorf("TempoChanges() = %v, want 1000000 at tick 0", got)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+	if d.Len() != 3 || d.At(0).Len() != 3 || d.At(1).Len() != 0 || d.At(2).Len() != 3 {
+		t.Errorf("track lengths = %d, %d, %d, want 3, 0, 3",
+			d.At(0).Len(), d.At(1).Len(), d.At(2).Len())
+	}
+	if got := d.TickToSeconds(96); got != 1 {
+		t.Errorf("TickToSeconds(96) = %v, want 1", got)
+	}
+	if tick, event := m.NextEvent(1); event != nil {
+		t.Errorf("NextEvent(1) = %d, % X on a track left out", tick, event)
+	}
+	if ts := d.TimeSignatures()[0]; ts.BeatPerBar != 3 || ts.BeatUnit != 4 {
+		t.Errorf("time signature = %d/%d, want 3/4", ts.BeatPerBar, ts.BeatUnit)
+	}
+
+	// Leaving out the conductor track keeps its timing.
+	m, err = ReadWithOptions(bytes.NewReader(buildSMF(1, 96, conductor, notes)),
+		ReadOptions{Tracks: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = BuildMIDIDataFromMIDIFile(m)
+	if got, want := d.TickToSeconds(96), m.TickToSeconds(96); got != want || got != 1 {
+		t.Errorf("TickToSeconds(96) = %v, MIDIFile has %v, want 1", got, want)
+	}
+
+	if _, err := ReadWithOptions(bytes.NewReader(data), ReadOptions{Tracks: []int{3}}); err == nil {
+		t.Error("ReadWithOptions() should fail for track 3")
+	}
+}
+
+func TestEmbeddedImages(t *testing.T) {
+	png := []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0, 0, 0, 0x0D}
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 'J', 'F', 'I', 'F'}
+	data := buildSMF(0, 480, endOfTrack)
+	for _, c := range []Chunk{
+		{Type: "ART1", Data: png},
+		{Type: "XTRA", Data: []byte("no image here")},
+		{Type: "ART2", Data: append([]byte("vendor"), jpeg...)},
+	} {
+		data = append(data, c.Type...)
+		data = append(data, 0, 0, 0, byte(len(c.Data)))
+		data = append(data, c.Data...)
+	}
+
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{png, jpeg}
+	if got := m.EmbeddedImages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("EmbeddedImages() = % X, want % X", got, want)
+	}
+
+	m, err = Read(bytes.NewReader(buildSMF(0, 480, endOfTrack)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.EmbeddedImages(); len(got) != 0 {
+		t.Errorf("EmbeddedImages() = % X, want none", got)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	track := []byte{
+		0x00, 0x3C, 0x64, // data bytes without status
+		0x00, 0x90, 0x3C, 0x64,
+		0x60, 0x80, 0x3C, 0x00,
+	}
+	data := buildSMF(0, 480, track)
+	binary.BigEndian.PutUint32(data[18:22], 20)
+
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Read() error = %v, want ErrTruncated", err)
+	}
+	data[21] = byte(len(track))
+	if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrBadEvent) {
+		t.Errorf("Read() error = %v, want ErrBadEvent", err)
+	}
+	data[21] = 20
+
+	m, err := ReadWithOptions(bytes.NewReader(data), ReadOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Warning{
+		{Track: 0, Offset: 18, Message: "track length 20 exceeds the file, using 11"},
+		{Track: 0, Offset: 23, Message: "running status without prior status, skipped 3 data bytes"},
+		{Track: 0, Offset: 33, Message: "missing end of track"},
+	}
+	if got := m.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+	notes := BuildMIDIDataFromMIDIFile(m).At(0).Notes()
+	if len(notes) != 1 || notes[0].End != 96 {
+		t.Errorf("Notes() = %v, want one note ending at 96", notes)
+	}
+	if got := m.Warnings(); len(got) != len(want) {
+		t.Errorf("building the data added warnings: %v", got)
+	}
+
+	noEnd := buildSMF(0, 480, []byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x80, 0x3C, 0x00})
+	if _, err := Read(bytes.NewReader(noEnd)); !errors.Is(err, ErrMissingEndOfTrack) {
+		t.Errorf("Read() error = %v, want ErrMissingEndOfTrack", err)
+	}
+	m, err = ReadWithOptions(bytes.NewReader(noEnd), ReadOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Warning{{Track: 0, Offset: 30, Message: "missing end of track"}}
+	if got := m.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+
+	twoEnds := buildSMF(0, 480, append(endOfTrack, endOfTrack...))
+	if _, err := Read(bytes.NewReader(twoEnds)); !errors.Is(err, ErrDataAfterEndOfTrack) {
+		t.Errorf("Read() error = %v, want ErrDataAfterEndOfTrack", err)
+	}
+	m, err = ReadWithOptions(bytes.NewReader(twoEnds), ReadOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Warning{{Track: 0, Offset: 26, Message: "data after end of track"}}
+	if got := m.Warnings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Warnings() = %v, want %v", got, want)
+	}
+}
+
+func TestReadRecover(t *testing.T) {
+	conductor := append([]byte{0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20}, endOfTrack...)
+	notes := append([]byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x80, 0x3C, 0x00}, endOfTrack...)
+	data := buildSMF(1, 480, conductor, notes)
+	data[21] -= 3 // the conductor track length is 3 bytes short
+
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("Read() should fail")
+	}
+	m, errs := ReadRecover(bytes.NewReader(data))
+	if m == nil || len(errs) != 1 {
+		t.Fatalf("ReadRecover() = %v, %v, want one error", m, errs)
+	}
+	var chunkErr *BadChunkError
+	if !errors.As(errs[0], &chunkErr) {
+		t.Errorf("error = %v, want a BadChunkError", errs[0])
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+	if d.Len() != 2 || d.At(0).Len() != 2 || len(d.At(1).Notes()) != 1 {
+		t.Errorf("recovered %d tracks, want both tracks intact", d.Len())
+	}
+
+	// The conductor track length is 3 bytes too long, running into the
+	// header of the next chunk.
+	data = buildSMF(1, 480, conductor, notes)
+	data[21] += 3
+	m, errs = ReadRecover(bytes.NewReader(data))
+	if m == nil || len(errs) != 1 || !errors.As(errs[0], &chunkErr) {
+		t.Fatalf("ReadRecover() = %v, %v, want one BadChunkError", m, errs)
+	}
+	d = BuildMIDIDataFromMIDIFile(m)
+	if d.Len() != 2 || d.At(0).Len() != 2 || len(d.At(1).Notes()) != 1 {
+		t.Errorf("recovered %d tracks, want both tracks intact", d.Len())
+	}
+
+	// A corrupt event in the last track, whose length runs past the end.
+	broken := []byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x80, 0x3C, 0x00, 0x00, 0xF4}
+	data = buildSMF(1, 480, conductor, broken)
+	data[40] += 5
+	m, errs = ReadRecover(bytes.NewReader(data))
+	if m == nil || len(errs) != 2 || !errors.Is(errs[0], ErrTruncated) ||
+		!errors.Is(errs[1], ErrBadEvent) {
+		t.Fatalf("ReadRecover() = %v, %v, want a truncation and a bad event", m, errs)
+	}
+	if got := BuildMIDIDataFromMIDIFile(m).At(1).Len(); got != 2 {
+		t.Errorf("recovered %d events, want 2", got)
+	}
+
+	// A second track that can't be found.
+	data = buildSMF(1, 480, conductor, notes)
+	copy(data[33:], "XXXX")
+	m, errs = ReadRecover(bytes.NewReader(data))
+	if m == nil || m.NumTracks != 1 || len(errs) != 1 {
+		t.Errorf("ReadRecover() = %v, %v, want one track and one error", m, errs)
+	}
+
+	if m, errs := ReadRecover(bytes.NewReader([]byte("RIFF"))); m != nil || len(errs) != 1 {
+		t.Errorf("ReadRecover() = %v, %v, want nil and one error", m, errs)
+	}
+	if m, errs := ReadRecover(bytes.NewReader(data[:14])); m != nil || len(errs) != 1 {
+		t.Errorf("ReadRecover() of a header = %v, %v, want nil and one error", m, errs)
+	}
+}
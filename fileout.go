@@ -0,0 +1,283 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// WriteOptions controls how MIDI data is written.
+type WriteOptions struct {
+	// ConvertFormat makes the writer emit Format instead of the format of
+	// the data: format 0 merges all tracks into one, and format 1 splits a
+	// single track by channel. Only formats 0 and 1 are supported.
+	ConvertFormat bool
+	Format        int
+
+	// NormalizeMetaTracks moves tempo, time signature and key signature
+	// events to the first track before writing format 1 data. See
+	// (*MIDIData).NormalizeMetaTracks.
+	NormalizeMetaTracks bool
+
+	// DropMetaTypes lists meta event types, such as 0x02 (copyright) or
+	// 0x7F (sequencer specific), that are left out of the file. End of
+	// track (0x2F) is always written.
+	DropMetaTypes []byte
+
+	// ExtraChunks are written after the track chunks, e.g. to carry
+	// vendor data read with MIDIFile.UnknownChunks. Their types must be
+	// four printable ASCII characters other than MThd and MTrk.
+	ExtraChunks []Chunk
+
+	// TrackNames writes the Name of each track as a track name (FF 03)
+	// event at tick 0, unless the track already has a track name event.
+	TrackNames bool
+
+	// Encoder encodes the names written by TrackNames. It defaults to
+	// EncodeLatin1, which matches the default charset of Read; pair
+	// EncodeUTF8 with the UTF8 charset to keep names outside Latin-1.
+	Encoder Encoder
+}
+
+// WriteMIDI writes d to a standard MIDI file.
+func WriteMIDI(filename string, d *MIDIData, opts WriteOptions) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = Write(file, d, opts)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Write writes d to w in the standard MIDI file format.
+func Write(w io.Writer, d *MIDIData, opts WriteOptions) error {
+	if opts.ConvertFormat {
+		switch opts.Format {
+		case 0:
+			d = d.ToFormat0()
+		case 1:
+			d = d.ToFormat1()
+		default:
+			return fmt.Errorf("cannot convert to format %d", opts.Format)
+		}
+	}
+
+	if opts.NormalizeMetaTracks && d.Format == 1 {
+		if !opts.ConvertFormat {
+			d = d.clone()
+		}
+		d.NormalizeMetaTracks()
+	}
+
+	if d.Format < 0 || d.Format > 2 {
+		return fmt.Errorf("invalid format: %d", d.Format)
+	}
+	if d.Format == 0 && len(d.tracks) != 1 {
+		return errors.New("format 0 requires exactly one track")
+	}
+	if len(d.tracks) > 0xFFFF {
+		return errors.New("too many tracks")
+	}
+	if !validDivision(d.Division) {
+		return fmt.Errorf("%w %d", ErrBadDivision, d.Division)
+	}
+
+	for _, c := range opts.ExtraChunks {
+		if !validChunkType(c.Type) || c.Type == "MThd" || c.Type == "MTrk" {
+			return fmt.Errorf("invalid chunk type %q", c.Type)
+		}
+		if int64(len(c.Data)) > 0xFFFFFFFF {
+			return fmt.Errorf("chunk %s too large", c.Type)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString("MThd")
+	binary.Write(bw, binary.BigEndian, uint32(6))
+	binary.Write(bw, binary.BigEndian, uint16(d.Format))
+	binary.Write(bw, binary.BigEndian, uint16(len(d.tracks)))
+	binary.Write(bw, binary.BigEndian, uint16(d.Division))
+
+	for _, t := range d.tracks {
+		payload, err := encodeTrack(t, opts)
+		if err != nil {
+			return err
+		}
+		bw.WriteString("MTrk")
+		binary.Write(bw, binary.BigEndian, uint32(len(payload)))
+		bw.Write(payload)
+	}
+	for _, c := range opts.ExtraChunks {
+		bw.WriteString(c.Type)
+		binary.Write(bw, binary.BigEndian, uint32(len(c.Data)))
+		bw.Write(c.Data)
+	}
+
+	return bw.Flush()
+}
+
+// EncodedSize returns the number of bytes Write would emit for d with
+// opts, e.g. for a Content-Length header, without keeping the encoded
+// file. It returns the error Write would return.
+func (d *MIDIData) EncodedSize(opts WriteOptions) (int64, error) {
+	var c byteCounter
+	if err := Write(&c, d, opts); err != nil {
+		return 0, err
+	}
+	return int64(c), nil
+}
+
+// byteCounter is an io.Writer that only counts the bytes written to it.
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// encodeTrack returns the MTrk payload of t, adjusted as requested by
+// opts. Events are written in tick order, and a single end of track event
+// is placed after the last event (or at the tick of the track's own end
+// of track event, if later).
+func encodeTrack(t *MIDITrack, opts WriteOptions) ([]byte, error) {
+	var events []*MIDIEvent
+	if opts.TrackNames && t.Name != "" && !t.hasMeta(0x03) {
+		encode := opts.Encoder
+		if encode == nil {
+			encode = EncodeLatin1
+		}
+		events = append(events,
+			&MIDIEvent{tick: 0, message: metaText(0x03, string(encode(t.Name)))})
+	}
+	events = append(events, t.events...)
+	drop := opts.DropMetaTypes
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].tick < events[j].tick
+	})
+
+	var buf bytes.Buffer
+	var tick int64
+	for _, e := range events {
+		if len(e.message) == 0 {
+			return nil, errors.New("empty event")
+		}
+		if e.tick < 0 {
+			return nil, fmt.Errorf("negative tick %d", e.tick)
+		}
+		if isEndOfTrack(e.message) || isDroppedMeta(e.message, drop) {
+			continue
+		}
+		buf.Write(encodeVarLen(uint64(e.tick - tick)))
+		buf.Write(e.message)
+		tick = e.tick
+	}
+
+	end := tick
+	if n := len(t.events); n > 0 && isEndOfTrack(t.events[n-1].message) &&
+		t.events[n-1].tick > end {
+		end = t.events[n-1].tick
+	}
+	buf.Write(encodeVarLen(uint64(end - tick)))
+	buf.Write([]byte{0xFF, 0x2F, 0x00})
+
+	return buf.Bytes(), nil
+}
+
+// isDroppedMeta reports whether message is a meta event of one of the
+// types in drop.
+func isDroppedMeta(message []uint8, drop []byte) bool {
+	for _, typ := range drop {
+		if isMetaEvent(message, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeVarLen encodes v as a variable-length quantity.
+func encodeVarLen(v uint64) []byte {
+	b := []byte{byte(v & 0x7F)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7F) | 0x80}, b...)
+	}
+	return b
+}
+
+// ToFormat0 returns a copy of d with all tracks merged into a single
+// track.
+func (d *MIDIData) ToFormat0() *MIDIData {
+	t := &MIDITrack{Name: d.Name}
+	for _, e := range d.mergedEvents() {
+		t.Append(e.clone())
+	}
+	t.Append(&MIDIEvent{tick: d.LastTick(), message: []uint8{0xFF, 0x2F, 0x00}})
+
+	out := &MIDIData{
+		Name:     d.Name,
+		Format:   0,
+		Division: d.Division,
+	}
+	out.Append(t)
+	out.updateMaps()
+	return out
+}
+
+// ToFormat1 returns a copy of d in format 1. Data with a single track is
+// split into a first track holding the meta and system exclusive events
+// followed by one track per channel; otherwise the tracks are copied as
+// they are.
+func (d *MIDIData) ToFormat1() *MIDIData {
+	out := &MIDIData{
+		Name:     d.Name,
+		Format:   1,
+		Division: d.Division,
+	}
+
+	if len(d.tracks) != 1 {
+		for _, t := range d.tracks {
+			out.Append(t.clone())
+		}
+		out.updateMaps()
+		return out
+	}
+
+	conductor := &MIDITrack{Name: d.tracks[0].Name}
+	var channels [16]*MIDITrack
+	for _, e := range d.tracks[0].events {
+		if isEndOfTrack(e.message) {
+			continue
+		}
+		ch, ok := channelOf(e.message)
+		if !ok {
+			conductor.Append(e.clone())
+			continue
+		}
+		if channels[ch] == nil {
+			channels[ch] = &MIDITrack{}
+		}
+		channels[ch].Append(e.clone())
+	}
+
+	end := d.LastTick()
+	out.Append(conductor)
+	for _, t := range channels {
+		if t != nil {
+			out.Append(t)
+		}
+	}
+	for _, t := range out.tracks {
+		t.Append(&MIDIEvent{tick: end, message: []uint8{0xFF, 0x2F, 0x00}})
+	}
+	out.updateMaps()
+	return out
+}
@@ -0,0 +1,284 @@
+package midi
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func readTestData(t testing.TB) *MIDIData {
+	m, err := ReadMIDI("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BuildMIDIDataFromMIDIFile(m)
+}
+
+func writeAndRead(t testing.TB, d *MIDIData, opts WriteOptions) *MIDIData {
+	var buf bytes.Buffer
+	if err := Write(&buf, d, opts); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BuildMIDIDataFromMIDIFile(m)
+}
+
+func sameEvents(a, b *MIDITrack) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := 0; i < a.Len(); i++ {
+		if a.At(i).Tick() != b.At(i).Tick() ||
+			!bytes.Equal(a.At(i).Message(), b.At(i).Message()) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	d := readTestData(t)
+	got := writeAndRead(t, d, WriteOptions{})
+
+	if got.Format != d.Format || got.Division != d.Division ||
+		got.Len() != d.Len() {
+		t.Fatalf("header mismatch: got %d/%d/%d, want %d/%d/%d",
+			got.Format, got.Division, got.Len(), d.Format, d.Division, d.Len())
+	}
+	for i := 0; i < d.Len(); i++ {
+		if !sameEvents(got.At(i), d.At(i)) {
+			t.Errorf("track %d differs after round trip", i)
+		}
+	}
+
+	var a, b bytes.Buffer
+	Write(&a, d, WriteOptions{})
+	Write(&b, got, WriteOptions{})
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Errorf("writing is not stable")
+	}
+}
+
+func TestWriteFormat0(t *testing.T) {
+	d := readTestData(t)
+	got := writeAndRead(t, d, WriteOptions{ConvertFormat: true, Format: 0})
+
+	if got.Format != 0 || got.Len() != 1 {
+		t.Fatalf("got format %d with %d tracks", got.Format, got.Len())
+	}
+	want := d.At(0).Len() + d.At(1).Len() - 1
+	if n := got.At(0).Len(); n != want {
+		t.Errorf("merged track has %d events, want %d", n, want)
+	}
+	if got.Duration() != d.Duration() {
+		t.Errorf("duration changed: %f != %f", got.Duration(), d.Duration())
+	}
+
+	back := writeAndRead(t, got, WriteOptions{ConvertFormat: true, Format: 1})
+	if back.Format != 1 || back.Len() != 2 {
+		t.Errorf("got format %d with %d tracks, want 1 and 2",
+			back.Format, back.Len())
+	}
+}
+
+func TestWriteInvalidFormat(t *testing.T) {
+	d := readTestData(t)
+	var buf bytes.Buffer
+	if err := Write(&buf, d, WriteOptions{ConvertFormat: true, Format: 2}); err == nil {
+		t.Errorf("converting to format 2 should fail")
+	}
+	d.Format = 0
+	if err := Write(&buf, d, WriteOptions{}); err == nil {
+		t.Errorf("format 0 with two tracks should fail")
+	}
+}
+
+func TestEncodeVarLen(t *testing.T) {
+	cases := []struct {
+		v    uint64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7F, []byte{0x7F}},
+		{0x80, []byte{0x81, 0x00}},
+		{0x2000, []byte{0xC0, 0x00}},
+		{0x0FFFFFFF, []byte{0xFF, 0xFF, 0xFF, 0x7F}},
+	}
+	for _, c := range cases {
+		if got := encodeVarLen(c.v); !bytes.Equal(got, c.want) {
+			t.Errorf("encodeVarLen(%X) = % X, want % X", c.v, got, c.want)
+		}
+	}
+}
+
+func TestWriteDropMetaTypes(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x02, 0x01, 'c'}},
+		&MIDIEvent{tick: 0, message: tempoMessage(100)},
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x7F, 0x01, 0x41}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+	got := writeAndRead(t, d, WriteOptions{
+		DropMetaTypes: []byte{0x02, 0x7F, 0x2F},
+	})
+
+	want := [][]uint8{
+		tempoMessage(100),
+		{0x90, 60, 100},
+		{0x80, 60, 0},
+		{0xFF, 0x2F, 0x00},
+	}
+	track := got.At(0)
+	if track.Len() != len(want) {
+		t.Fatalf("got %d events, want %d", track.Len(), len(want))
+	}
+	for i, msg := range want {
+		if !bytes.Equal(track.At(i).Message(), msg) {
+			t.Errorf("event %d = % X, want % X", i, track.At(i).Message(), msg)
+		}
+	}
+	if tick := track.At(track.Len() - 1).Tick(); tick != 960 {
+		t.Errorf("end of track at %d, want 960", tick)
+	}
+}
+
+func TestWriteExtraChunks(t *testing.T) {
+	d := readTestData(t)
+	chunks := []Chunk{
+		{Type: "XVND", Data: []byte{1, 2, 3}},
+		{Type: "XEMP", Data: []byte{}},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, d, WriteOptions{ExtraChunks: chunks}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.UnknownChunks(); !reflect.DeepEqual(got, chunks) {
+		t.Errorf("UnknownChunks() = %v, want %v", got, chunks)
+	}
+
+	for _, typ := range []string{"ABC", "ABCDE", "MTrk", "AB\x00C"} {
+		opts := WriteOptions{ExtraChunks: []Chunk{{Type: typ}}}
+		if err := Write(ioutil.Discard, d, opts); err == nil {
+			t.Errorf("chunk type %q accepted", typ)
+		}
+	}
+}
+
+func TestWriteTrackNames(t *testing.T) {
+	named := newTestTrack(&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}})
+	named.Name = "Bass"
+	existing := newTestTrack(&MIDIEvent{tick: 0, message: metaText(0x03, "Lead")})
+	existing.Name = "Ignored"
+	d := newTestData(480, named, existing, newTestTrack())
+
+	got := writeAndRead(t, d, WriteOptions{TrackNames: true})
+	for i, want := range []string{"Bass", "Lead", ""} {
+		if name := got.At(i).Name; name != want {
+			t.Errorf("track %d: Name = %q, want %q", i, name, want)
+		}
+	}
+	if n := len(got.At(1).MetaEvents(0x03)); n != 1 {
+		t.Errorf("track 1 has %d track names, want 1", n)
+	}
+
+	got = writeAndRead(t, d, WriteOptions{})
+	if name := got.At(0).Name; name != "" {
+		t.Errorf("Name = %q without TrackNames, want empty", name)
+	}
+
+	// Names outside ASCII read back with the matching charset.
+	named.Name = "Café"
+	got = writeAndRead(t, d, WriteOptions{TrackNames: true})
+	if name := got.At(0).Name; name != "Café" {
+		t.Errorf("Latin-1 Name = %q, want %q", name, "Café")
+	}
+	named.Name = "ベース"
+	var buf bytes.Buffer
+	opts := WriteOptions{TrackNames: true, Encoder: EncodeUTF8}
+	if err := Write(&buf, d, opts); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadWithOptions(&buf, ReadOptions{Charset: UTF8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := BuildMIDIDataFromMIDIFile(m).At(0).Name; name != "ベース" {
+		t.Errorf("UTF-8 Name = %q, want %q", name, "ベース")
+	}
+}
+
+func TestWriteSMPTEDivision(t *testing.T) {
+	if _, err := SMPTEDivision(23, 40); !errors.Is(err, ErrBadDivision) {
+		t.Errorf("SMPTEDivision(23, 40) error = %v, want ErrBadDivision", err)
+	}
+	if _, err := SMPTEDivision(25, 0); !errors.Is(err, ErrBadDivision) {
+		t.Errorf("SMPTEDivision(25, 0) error = %v, want ErrBadDivision", err)
+	}
+
+	division, err := SMPTEDivision(29, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := newTestData(division, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 80, message: []uint8{0x80, 60, 0}},
+	))
+	d.Format = 0
+
+	var buf bytes.Buffer
+	if err := Write(&buf, d, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.Bytes()[12:14]; !bytes.Equal(got, []byte{0xE3, 0x50}) {
+		t.Errorf("division bytes = % X, want E3 50", got)
+	}
+	m, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fps, drop := m.SMPTEFormat(); !drop || m.Division != division {
+		t.Errorf("SMPTEFormat() = %g, %v, Division = %d, want drop frame %d",
+			fps, drop, m.Division, division)
+	}
+
+	d.Division = -23<<8 | 80
+	if err := Write(&buf, d, WriteOptions{}); !errors.Is(err, ErrBadDivision) {
+		t.Errorf("Write() error = %v, want ErrBadDivision", err)
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	d := readTestData(t)
+	for _, opts := range []WriteOptions{
+		{},
+		{ConvertFormat: true, Format: 0},
+		{TrackNames: true, ExtraChunks: []Chunk{{Type: "XTRA", Data: []byte{1, 2, 3}}}},
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, d, opts); err != nil {
+			t.Fatal(err)
+		}
+		size, err := d.EncodedSize(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != int64(buf.Len()) {
+			t.Errorf("EncodedSize(%+v) = %d, want %d", opts, size, buf.Len())
+		}
+	}
+
+	if _, err := d.EncodedSize(WriteOptions{ConvertFormat: true, Format: 2}); err == nil {
+		t.Error("EncodedSize() should fail like Write")
+	}
+}
@@ -0,0 +1,66 @@
+package midi
+
+import (
+	"math/rand"
+)
+
+// fillSteps is the number of grid steps a fill is divided into.
+const fillSteps = 16
+
+// fillToms lists the General MIDI toms from high to low, the order in
+// which a fill moves around the kit.
+var fillToms = []uint8{50, 48, 47, 45, 43, 41}
+
+// fillSnare is the General MIDI acoustic snare.
+const fillSnare = 38
+
+// GenerateFill adds a drum fill to the track on PercussionChannel in the
+// region of lengthTicks starting at startTick. The region is divided into
+// 16 steps (or steps of one tick if it is shorter), and each step is hit
+// with probability density, from 0 to 1. Hits are snare or toms, with the
+// toms moving from high to low over the fill, and steps on the beat, i.e.
+// every fourth step, are accented. The pattern only depends on seed, so
+// the same seed gives the same fill. It returns the number of hits added.
+func (t *MIDITrack) GenerateFill(startTick, lengthTicks int64, density float64, seed int64) int {
+	if startTick < 0 || lengthTicks <= 0 || density <= 0 {
+		return 0
+	}
+	steps, step := int64(fillSteps), lengthTicks/fillSteps
+	if step == 0 {
+		steps, step = lengthTicks, 1
+	}
+	length := step / 2
+	if length < 1 {
+		length = 1
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	hits := 0
+	for i := int64(0); i < steps; i++ {
+		// Draw every number whether or not the step is hit, so that a
+		// higher density only adds hits to the same pattern.
+		hit := rng.Float64() < density
+		snare := rng.Float64() < 0.3
+		velocity := 70 + rng.Intn(40)
+		if !hit {
+			continue
+		}
+
+		key := uint8(fillSnare)
+		if !snare {
+			key = fillToms[i*int64(len(fillToms))/steps]
+		}
+		if i%4 == 0 {
+			velocity += 15
+		}
+		tick := startTick + i*step
+		t.events = append(t.events,
+			&MIDIEvent{tick: tick, message: []uint8{
+				0x90 | PercussionChannel, key, clampDataByte(velocity)}},
+			&MIDIEvent{tick: tick + length, message: []uint8{
+				0x80 | PercussionChannel, key, 0}})
+		hits++
+	}
+	t.sortEvents()
+	return hits
+}
@@ -0,0 +1,57 @@
+package midi
+
+import (
+	"testing"
+)
+
+func TestGenerateFill(t *testing.T) {
+	newTrack := func() *MIDITrack {
+		return newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+			&MIDIEvent{tick: 60, message: []uint8{0x89, 36, 0}},
+			&MIDIEvent{tick: 1920, message: []uint8{0xFF, 0x2F, 0x00}},
+		)
+	}
+
+	full := newTrack()
+	if n := full.GenerateFill(1920, 1920, 1, 42); n != 16 {
+		t.Fatalf("GenerateFill() = %d hits, want 16", n)
+	}
+	if !isEndOfTrack(full.At(full.Len() - 1).Message()) {
+		t.Error("end of track isn't last")
+	}
+	for i, n := range full.Notes()[1:] {
+		if n.Channel != PercussionChannel || n.Start != 1920+int64(i)*120 ||
+			n.Duration() != 60 {
+			t.Errorf("hit %d = %+v", i, n)
+		}
+		if n.Key != fillSnare && (n.Key < 41 || n.Key > 50) {
+			t.Errorf("hit %d key = %d, want a snare or tom", i, n.Key)
+		}
+	}
+
+	again := newTrack()
+	again.GenerateFill(1920, 1920, 1, 42)
+	if !again.Equal(full) {
+		t.Error("the same seed gave a different fill")
+	}
+
+	sparse := newTrack()
+	n := sparse.GenerateFill(1920, 1920, 0.5, 42)
+	if n == 0 || n == 16 {
+		t.Errorf("GenerateFill() with density 0.5 = %d hits", n)
+	}
+	starts := make(map[int64]int)
+	for _, note := range full.Notes() {
+		starts[note.Start] = note.Key
+	}
+	for _, note := range sparse.Notes() {
+		if key, ok := starts[note.Start]; !ok || key != note.Key {
+			t.Errorf("sparse hit %+v isn't in the full fill", note)
+		}
+	}
+
+	if n := newTrack().GenerateFill(1920, 1920, 0, 42); n != 0 {
+		t.Errorf("GenerateFill() with density 0 = %d hits", n)
+	}
+}
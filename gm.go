@@ -0,0 +1,188 @@
+package midi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GMProgramNames lists the General MIDI Level 1 instrument names indexed
+// by zero-based program number.
+var GMProgramNames = [128]string{
+	// Piano
+	"Acoustic Grand Piano", "Bright Acoustic Piano", "Electric Grand Piano",
+	"Honky-tonk Piano", "Electric Piano 1", "Electric Piano 2", "Harpsichord",
+	"Clavi",
+	// Chromatic Percussion
+	"Celesta", "Glockenspiel", "Music Box", "Vibraphone", "Marimba",
+	"Xylophone", "Tubular Bells", "Dulcimer",
+	// Organ
+	"Drawbar Organ", "Percussive Organ", "Rock Organ", "Church Organ",
+	"Reed Organ", "Accordion", "Harmonica", "Tango Accordion",
+	// Guitar
+	"Acoustic Guitar (nylon)", "Acoustic Guitar (steel)",
+	"Electric Guitar (jazz)", "Electric Guitar (clean)",
+	"Electric Guitar (muted)", "Overdriven Guitar", "Distortion Guitar",
+	"Guitar harmonics",
+	// Bass
+	"Acoustic Bass", "Electric Bass (finger)", "Electric Bass (pick)",
+	"Fretless Bass", "Slap Bass 1", "Slap Bass 2", "Synth Bass 1",
+	"Synth Bass 2",
+	// Strings
+	"Violin", "Viola", "Cello", "Contrabass", "Tremolo Strings",
+	"Pizzicato Strings", "Orchestral Harp", "Timpani",
+	// Ensemble
+	"String Ensemble 1", "String Ensemble 2", "SynthStrings 1",
+	"SynthStrings 2", "Choir Aahs", "Voice Oohs", "Synth Voice",
+	"Orchestra Hit",
+	// Brass
+	"Trumpet", "Trombone", "Tuba", "Muted Trumpet", "French Horn",
+	"Brass Section", "SynthBrass 1", "SynthBrass 2",
+	// Reed
+	"Soprano Sax", "Alto Sax", "Tenor Sax", "Baritone Sax", "Oboe",
+	"English Horn", "Bassoon", "Clarinet",
+	// Pipe
+	"Piccolo", "Flute", "Recorder", "Pan Flute", "Blown Bottle",
+	"Shakuhachi", "Whistle", "Ocarina",
+	// Synth Lead
+	"Lead 1 (square)", "Lead 2 (sawtooth)", "Lead 3 (calliope)",
+	"Lead 4 (chiff)", "Lead 5 (charang)", "Lead 6 (voice)",
+	"Lead 7 (fifths)", "Lead 8 (bass + lead)",
+	// Synth Pad
+	"Pad 1 (new age)", "Pad 2 (warm)", "Pad 3 (polysynth)", "Pad 4 (choir)",
+	"Pad 5 (bowed)", "Pad 6 (metallic)", "Pad 7 (halo)", "Pad 8 (sweep)",
+	// Synth Effects
+	"FX 1 (rain)", "FX 2 (soundtrack)", "FX 3 (crystal)",
+	"FX 4 (atmosphere)", "FX 5 (brightness)", "FX 6 (goblins)",
+	"FX 7 (echoes)", "FX 8 (sci-fi)",
+	// Ethnic
+	"Sitar", "Banjo", "Shamisen", "Koto", "Kalimba", "Bag pipe", "Fiddle",
+	"Shanai",
+	// Percussive
+	"Tinkle Bell", "Agogo", "Steel Drums", "Woodblock", "Taiko Drum",
+	"Melodic Tom", "Synth Drum", "Reverse Cymbal",
+	// Sound Effects
+	"Guitar Fret Noise", "Breath Noise", "Seashore", "Bird Tweet",
+	"Telephone Ring", "Helicopter", "Applause", "Gunshot",
+}
+
+// GMProgram returns the program number of a General MIDI instrument
+// name. The comparison ignores case.
+func GMProgram(name string) (int, bool) {
+	for i, n := range GMProgramNames {
+		if strings.EqualFold(n, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// The range of keys of the General MIDI Level 1 percussion map.
+const (
+	gmPercussionLow  = 35 // Acoustic Bass Drum
+	gmPercussionHigh = 81 // Open Triangle
+)
+
+// GMCompliance checks that the data plays as intended on any General MIDI
+// Level 1 device. It reports:
+//
+//   - a missing GM System On message among the setup events, or a GM2, GS
+//     or XG reset, which GM devices don't understand
+//   - program changes on PercussionChannel, which GM uses for a single kit
+//   - notes on PercussionChannel outside the GM percussion map
+//   - program numbers outside 0..127
+//   - bank selects other than bank 0
+//   - NRPNs, RPNs other than pitch bend sensitivity and fine and coarse
+//     tuning, and data entry without a parameter number
+//
+// Each distinct problem is reported once per track and channel, at its
+// first tick, in the order found.
+func (d *MIDIData) GMCompliance() []Issue {
+	var issues []Issue
+	seen := make(map[Issue]bool)
+	report := func(track, channel int, tick int64, format string, args ...interface{}) {
+		key := Issue{Track: track, Channel: channel, Message: fmt.Sprintf(format, args...)}
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		key.Tick, key.Confidence = tick, 1
+		issues = append(issues, key)
+	}
+
+	gm := false
+	for _, s := range d.SetupSysEx() {
+		switch r := s.ResetType(); r {
+		case "GM":
+			gm = true
+		case "":
+		default:
+			report(0, -1, 0, "%s reset isn't General MIDI", r)
+		}
+	}
+	if !gm {
+		report(-1, -1, 0, "no GM System On message")
+	}
+
+	// Selected parameter number of each channel, -1 for none and -2 for an
+	// NRPN.
+	var param [16]int
+	for ch := range param {
+		param[ch] = -1
+	}
+	var rpn [16][2]uint8
+	for ch := range rpn {
+		rpn[ch] = [2]uint8{127, 127}
+	}
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		ch, ok := channelOf(e.message)
+		if !ok {
+			return true
+		}
+		msg := e.message
+		switch msg[0] & 0xF0 {
+		case 0x90:
+			if len(msg) == 3 && ch == PercussionChannel && msg[2] > 0 &&
+				(msg[1] < gmPercussionLow || msg[1] > gmPercussionHigh) {
+				report(track, ch, e.tick, "key %d isn't in the GM percussion map", msg[1])
+			}
+		case 0xC0:
+			if len(msg) != 2 {
+				break
+			}
+			if msg[1] > 127 {
+				report(track, ch, e.tick, "invalid program %d", msg[1])
+			}
+			if ch == PercussionChannel {
+				report(track, ch, e.tick, "program change on percussion channel")
+			}
+		case 0xB0:
+			if len(msg) != 3 {
+				break
+			}
+			switch msg[1] {
+			case 0, 32:
+				if msg[2] != 0 {
+					report(track, ch, e.tick, "bank select isn't General MIDI")
+				}
+			case 98, 99:
+				param[ch] = -2
+				report(track, ch, e.tick, "NRPN isn't General MIDI")
+			case 100, 101:
+				rpn[ch][101-msg[1]] = msg[2]
+				param[ch] = -1
+				if rpn[ch] != [2]uint8{127, 127} {
+					param[ch] = int(rpn[ch][0])<<7 | int(rpn[ch][1])
+				}
+			case 6, 38, 96, 97:
+				switch p := param[ch]; {
+				case p == -1:
+					report(track, ch, e.tick, "data entry without parameter number")
+				case p > 2:
+					report(track, ch, e.tick, "RPN %d isn't General MIDI", p)
+				}
+			}
+		}
+		return true
+	})
+	return issues
+}
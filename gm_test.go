@@ -0,0 +1,70 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGMCompliance(t *testing.T) {
+	gmOn := []uint8{0xF0, 0x05, 0x7E, 0x7F, 0x09, 0x01, 0xF7}
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: gmOn},
+			&MIDIEvent{tick: 0, message: []uint8{0xB0, 101, 0}},
+			&MIDIEvent{tick: 0, message: []uint8{0xB0, 100, 0}},
+			&MIDIEvent{tick: 0, message: []uint8{0xB0, 6, 12}},
+			&MIDIEvent{tick: 0, message: []uint8{0xC0, 0}},
+			&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+			&MIDIEvent{tick: 120, message: []uint8{0x89, 36, 0}},
+			&MIDIEvent{tick: 120, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+	)
+	if got := d.GMCompliance(); len(got) != 0 {
+		t.Errorf("GMCompliance() = %v, want no issues", got)
+	}
+
+	d = newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xB1, 0, 8}},
+			&MIDIEvent{tick: 0, message: []uint8{0xC1, 4}},
+			&MIDIEvent{tick: 0, message: []uint8{0xB1, 6, 2}},
+			&MIDIEvent{tick: 10, message: []uint8{0xB1, 99, 1}},
+			&MIDIEvent{tick: 10, message: []uint8{0xB1, 98, 8}},
+			&MIDIEvent{tick: 20, message: []uint8{0xB1, 101, 0}},
+			&MIDIEvent{tick: 20, message: []uint8{0xB1, 100, 5}},
+			&MIDIEvent{tick: 20, message: []uint8{0xB1, 6, 64}},
+			&MIDIEvent{tick: 30, message: []uint8{0xB1, 101, 127}},
+			&MIDIEvent{tick: 30, message: []uint8{0xB1, 100, 127}},
+			&MIDIEvent{tick: 30, message: []uint8{0xB1, 6, 64}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xC9, 16}},
+			&MIDIEvent{tick: 0, message: []uint8{0x99, 27, 100}},
+			&MIDIEvent{tick: 60, message: []uint8{0x89, 27, 0}},
+			&MIDIEvent{tick: 60, message: []uint8{0x99, 27, 100}},
+		),
+	)
+	want := []string{
+		"tick 0: no GM System On message",
+		"track 0, channel 1, tick 0: bank select isn't General MIDI",
+		"track 0, channel 1, tick 0: data entry without parameter number",
+		"track 1, channel 9, tick 0: program change on percussion channel",
+		"track 1, channel 9, tick 0: key 27 isn't in the GM percussion map",
+		"track 0, channel 1, tick 10: NRPN isn't General MIDI",
+		"track 0, channel 1, tick 20: RPN 5 isn't General MIDI",
+	}
+	var got []string
+	for _, issue := range d.GMCompliance() {
+		got = append(got, issue.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GMCompliance() =\n%q\nwant\n%q", got, want)
+	}
+
+	gs := []uint8{0xF0, 0x0A, 0x41, 0x10, 0x42, 0x12, 0x40, 0x00, 0x7F, 0x00, 0x41, 0xF7}
+	d = newTestData(480, newTestTrack(&MIDIEvent{tick: 0, message: gs}))
+	if got := d.GMCompliance(); len(got) != 2 ||
+		got[0].Message != "GS reset isn't General MIDI" {
+		t.Errorf("GMCompliance() with GS reset = %v", got)
+	}
+}
@@ -0,0 +1,141 @@
+package midi
+
+import (
+	"math"
+)
+
+// grooveSteps is the number of grid positions in a groove extracted by
+// ExtractGroove, i.e. one 4/4 bar when the grid is a sixteenth note.
+const grooveSteps = 16
+
+// Groove is a timing and dynamics feel: for each position of a repeating
+// cycle of grid steps it holds how far notes are played from the grid
+// and how much louder or softer than average they are.
+type Groove struct {
+	Grid     int64   // length of a step in ticks
+	Timing   []int64 // offset from the grid position in ticks, per step
+	Velocity []int   // offset from the average velocity, per step
+}
+
+// gridStep returns the index of the grid position nearest to tick and
+// the offset of tick from it.
+func gridStep(tick, grid int64) (int64, int64) {
+	i := (tick + grid/2) / grid
+	return i, tick - i*grid
+}
+
+// ExtractGroove measures the feel of the track over cycles of 16 grid
+// steps, starting at tick 0. Each note on is assigned to its nearest grid
+// position, and the offsets of all notes at the same position of the
+// cycle are averaged. Positions without notes have zero offsets.
+func (t *MIDITrack) ExtractGroove(grid int64) Groove {
+	g := Groove{
+		Grid:     grid,
+		Timing:   make([]int64, grooveSteps),
+		Velocity: make([]int, grooveSteps),
+	}
+	if grid <= 0 {
+		return g
+	}
+
+	var timing, velocity [grooveSteps]float64
+	var count [grooveSteps]int
+	var total float64
+	n := 0
+	for _, e := range t.events {
+		if _, _, on, ok := noteEvent(e.message); !ok || !on {
+			continue
+		}
+		i, offset := gridStep(e.tick, grid)
+		step := i % grooveSteps
+		timing[step] += float64(offset)
+		velocity[step] += float64(e.message[2])
+		count[step]++
+		total += float64(e.message[2])
+		n++
+	}
+	if n == 0 {
+		return g
+	}
+
+	mean := total / float64(n)
+	for step, c := range count {
+		if c == 0 {
+			continue
+		}
+		g.Timing[step] = int64(math.Floor(timing[step]/float64(c) + 0.5))
+		g.Velocity[step] = int(math.Floor(velocity[step]/float64(c) - mean + 0.5))
+	}
+	return g
+}
+
+// ApplyGroove moves each note of the track by the timing offset of its
+// nearest grid position in g, keeping its length, and adds the velocity
+// offset of that position to its velocity. Notes are never moved before
+// tick 0.
+func (t *MIDITrack) ApplyGroove(g Groove) {
+	if g.Grid <= 0 || len(g.Timing) == 0 {
+		return
+	}
+
+	for _, p := range t.notePairs() {
+		i, _ := gridStep(p.on.tick, g.Grid)
+		step := int(i % int64(len(g.Timing)))
+
+		shift := g.Timing[step]
+		if p.on.tick+shift < 0 {
+			shift = -p.on.tick
+		}
+		p.on.tick += shift
+		if p.off != nil {
+			p.off.tick += shift
+		}
+
+		if step < len(g.Velocity) {
+			v := int(p.on.message[2]) + g.Velocity[step]
+			if v < 1 {
+				v = 1
+			}
+			p.on.message[2] = clampDataByte(v)
+		}
+	}
+	t.sortEvents()
+}
+
+// TimingError is the deviation of a note on from the nearest grid point.
+type TimingError struct {
+	NoteTick   int64
+	ErrorTicks int64 // positive if the note is late, negative if early
+}
+
+// TimingErrors returns, for every note on of the track in order, its
+// signed distance to the nearest multiple of referenceGrid ticks. It
+// returns nil if referenceGrid isn't positive.
+func (t *MIDITrack) TimingErrors(referenceGrid int64) []TimingError {
+	if referenceGrid <= 0 {
+		return nil
+	}
+	var errs []TimingError
+	for _, e := range t.events {
+		if _, _, on, ok := noteEvent(e.message); ok && on {
+			_, offset := gridStep(e.tick, referenceGrid)
+			errs = append(errs, TimingError{NoteTick: e.tick, ErrorTicks: offset})
+		}
+	}
+	return errs
+}
+
+// TimingRMS returns the root mean square of the TimingErrors of the track
+// in ticks, a single measure of how tightly it is played. It returns 0
+// for a track without notes.
+func (t *MIDITrack) TimingRMS(referenceGrid int64) float64 {
+	errs := t.TimingErrors(referenceGrid)
+	if len(errs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, e := range errs {
+		sum += float64(e.ErrorTicks) * float64(e.ErrorTicks)
+	}
+	return math.Sqrt(sum / float64(len(errs)))
+}
@@ -0,0 +1,75 @@
+package midi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGroove(t *testing.T) {
+	// Sixteenths at 120 ticks whose off-beats are late and soft.
+	var events []*MIDIEvent
+	for i := int64(0); i < 32; i++ {
+		tick, vel := i*120, uint8(100)
+		if i%2 == 1 {
+			tick, vel = tick+20, 80
+		}
+		events = append(events,
+			&MIDIEvent{tick: tick, message: []uint8{0x90, 60, vel}},
+			&MIDIEvent{tick: tick + 60, message: []uint8{0x80, 60, 0}})
+	}
+	ref := newTestTrack(events...)
+	ref.sortEvents()
+
+	g := ref.ExtractGroove(120)
+	if g.Grid != 120 || len(g.Timing) != 16 || len(g.Velocity) != 16 {
+		t.Fatalf("ExtractGroove(120) = %+v", g)
+	}
+	if g.Timing[0] != 0 || g.Timing[1] != 20 || g.Velocity[0] != 10 ||
+		g.Velocity[1] != -10 {
+		t.Errorf("ExtractGroove(120) = %+v, want offsets 0/20 and 10/-10", g)
+	}
+
+	target := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 64, 90}},
+		&MIDIEvent{tick: 100, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 120, message: []uint8{0x90, 64, 90}},
+		&MIDIEvent{tick: 200, message: []uint8{0x80, 64, 0}},
+	)
+	target.ApplyGroove(g)
+	want := []Note{
+		{Key: 64, Velocity: 100, Start: 0, End: 100},
+		{Key: 64, Velocity: 80, Start: 140, End: 220},
+	}
+	for i, n := range target.Notes() {
+		if n != want[i] {
+			t.Errorf("note %d = %v, want %v", i, n, want[i])
+		}
+	}
+}
+
+func TestTimingErrors(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 110, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 125, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 230, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 235, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 300, message: []uint8{0x80, 60, 0}},
+	)
+
+	want := []TimingError{
+		{NoteTick: 0, ErrorTicks: 0},
+		{NoteTick: 125, ErrorTicks: 5},
+		{NoteTick: 235, ErrorTicks: -5},
+	}
+	if got := track.TimingErrors(120); !reflect.DeepEqual(got, want) {
+		t.Errorf("TimingErrors(120) = %v, want %v", got, want)
+	}
+	if got, want := track.TimingRMS(120), math.Sqrt(50.0/3); math.Abs(got-want) > 1e-9 {
+		t.Errorf("TimingRMS(120) = %g, want %g", got, want)
+	}
+	if got := track.TimingErrors(0); got != nil {
+		t.Errorf("TimingErrors(0) = %v, want nil", got)
+	}
+}
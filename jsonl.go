@@ -0,0 +1,77 @@
+package midi
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// jsonEvent is a single line written by WriteJSONL.
+type jsonEvent struct {
+	Track   int     `json:"track"`
+	Tick    int64   `json:"tick"`
+	Seconds float64 `json:"seconds"`
+	Type    string  `json:"type"`
+	Message Message `json:"message,omitempty"`
+	Raw     []uint8 `json:"raw,omitempty"`
+}
+
+// messageType returns the name of the type of msg.
+func messageType(msg Message) string {
+	switch msg.(type) {
+	case NoteOff:
+		return "NoteOff"
+	case NoteOn:
+		return "NoteOn"
+	case PolyAftertouch:
+		return "PolyAftertouch"
+	case ControlChange:
+		return "ControlChange"
+	case ChannelMode:
+		return "ChannelMode"
+	case ProgramChange:
+		return "ProgramChange"
+	case ChannelPressure:
+		return "ChannelPressure"
+	case PitchBend:
+		return "PitchBend"
+	case SysEx:
+		return "SysEx"
+	case MetaEvent:
+		return "MetaEvent"
+	}
+	return "Invalid"
+}
+
+// WriteJSONL writes every event as a JSON object on its own line, track
+// by track. Each object holds the track index, tick, time in seconds,
+// message type and the decoded message; events that fail to decode have
+// type "Invalid" and carry their raw bytes instead. Events are encoded
+// one at a time, so memory use doesn't grow with the size of the data.
+func (d *MIDIData) WriteJSONL(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	for i, t := range d.tracks {
+		for _, e := range t.events {
+			line := jsonEvent{
+				Track:   i,
+				Tick:    e.tick,
+				Seconds: d.TickToSeconds(e.tick),
+			}
+			msg, err := ParseMessage(e.message)
+			if err != nil {
+				line.Raw = e.message
+			} else {
+				line.Message = msg
+			}
+			line.Type = messageType(line.Message)
+
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return bw.Flush()
+}
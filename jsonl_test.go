@@ -0,0 +1,46 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteJSONL(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: tempoMessage(60)},
+		&MIDIEvent{tick: 480, message: []uint8{0x92, 60, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x92}},
+	))
+
+	var buf bytes.Buffer
+	if err := d.WriteJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	note := lines[1]
+	if note["type"] != "NoteOn" || note["seconds"] != 1.0 {
+		t.Errorf("unexpected line %v", note)
+	}
+	fields := note["message"].(map[string]interface{})
+	if fields["Channel"] != 2.0 || fields["Key"] != 60.0 {
+		t.Errorf("unexpected message fields %v", fields)
+	}
+	if lines[2]["type"] != "Invalid" || lines[2]["raw"] == nil {
+		t.Errorf("unexpected line %v", lines[2])
+	}
+}
@@ -0,0 +1,130 @@
+package midi
+
+import (
+	"sort"
+)
+
+// LeadNote is a melody note placed in the bar structure. Bars are numbered
+// from 1, and Beat counts beats of the time signature from 1 at the start
+// of the bar, so a note half a beat into the bar has Beat 1.5.
+type LeadNote struct {
+	Note
+	Bar  int
+	Beat float64
+}
+
+// LeadSheet is a simplified score: a single melody line and a chord symbol
+// for each bar.
+type LeadSheet struct {
+	Melody []LeadNote
+	Chords []string // one per bar, "N.C." where nothing sounds
+}
+
+// LeadSheet reduces the data to a lead sheet. The melody is the Skyline of
+// all notes outside PercussionChannel, merged across tracks. The chord of
+// each bar is the major or minor triad whose tones cover the most sounding
+// time of those notes in the bar, ties going to the triad whose root
+// sounds longest; roots are spelled with flats if the first key signature
+// has flats. Bars follow the time signature map up to the end of the last
+// note. It returns an empty lead sheet for time-code division.
+func (d *MIDIData) LeadSheet() LeadSheet {
+	var sheet LeadSheet
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return sheet
+	}
+
+	merged := &MIDITrack{}
+	for _, e := range d.mergedEvents() {
+		if ch, ok := channelOf(e.message); ok && ch != PercussionChannel {
+			merged.Append(e.clone())
+		}
+	}
+	notes := merged.Notes()
+	if len(notes) == 0 {
+		return sheet
+	}
+	end := d.LastTick()
+	for _, n := range notes {
+		if n.End > end {
+			end = n.End
+		}
+	}
+
+	lines, err := d.barLines(end)
+	if err != nil {
+		return sheet
+	}
+	starts := append([]int64{0}, lines...)
+	barOf := func(tick int64) int {
+		return sort.Search(len(starts), func(i int) bool {
+			return starts[i] > tick
+		}) - 1
+	}
+	sigs := d.TimeSignatures()
+
+	for _, n := range merged.Skyline() {
+		bar := barOf(n.Start)
+		i := sort.Search(len(sigs), func(i int) bool {
+			return int64(sigs[i].Count) > starts[bar]
+		}) - 1
+		beat := float64(d.Division&0x7FFF) * 4 / float64(sigs[i].BeatUnit)
+		sheet.Melody = append(sheet.Melody, LeadNote{
+			Note: n,
+			Bar:  bar + 1,
+			Beat: 1 + float64(n.Start-starts[bar])/beat,
+		})
+	}
+
+	chroma := make([][12]int64, len(starts))
+	bounds := append(starts, end)
+	for _, n := range notes {
+		for bar := barOf(n.Start); bar < len(starts) && bounds[bar] < n.End; bar++ {
+			start, stop := bounds[bar], bounds[bar+1]
+			if n.Start > start {
+				start = n.Start
+			}
+			if n.End < stop {
+				stop = n.End
+			}
+			chroma[bar][n.Key%12] += stop - start
+		}
+	}
+
+	useFlats := false
+	if keys := d.KeySignatures(); len(keys) > 0 {
+		useFlats = keys[0].Sharps < 0
+	}
+	for _, c := range chroma {
+		sheet.Chords = append(sheet.Chords, chordName(c, useFlats))
+	}
+	return sheet
+}
+
+// chordName returns the symbol of the triad that best matches the time
+// each pitch class sounds, or "N.C." if none does.
+func chordName(chroma [12]int64, useFlats bool) string {
+	best, bestScore, bestRootTime := "N.C.", int64(0), int64(0)
+	for root := 0; root < 12; root++ {
+		for _, q := range []struct {
+			suffix string
+			third  int
+		}{{"", 4}, {"m", 3}} {
+			score := chroma[root] + chroma[(root+q.third)%12] + chroma[(root+7)%12]
+			if score == 0 || score < bestScore ||
+				score == bestScore && chroma[root] <= bestRootTime {
+				continue
+			}
+			letter, accidental, _ := spellKey(60+root, useFlats)
+			name := string("CDEFGAB"[letter])
+			switch accidental {
+			case 1:
+				name += "#"
+			case -1:
+				name += "b"
+			}
+			best = name + q.suffix
+			bestScore, bestRootTime = score, chroma[root]
+		}
+	}
+	return best
+}
@@ -0,0 +1,54 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLeadSheet(t *testing.T) {
+	melody := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 76, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 76, 0}},
+		&MIDIEvent{tick: 1200, message: []uint8{0x90, 72, 100}},
+		&MIDIEvent{tick: 2400, message: []uint8{0x80, 72, 0}},
+		&MIDIEvent{tick: 2400, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	chords := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 48, 80}},
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 52, 80}},
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 55, 80}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x81, 48, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x81, 52, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x81, 55, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x91, 46, 80}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x91, 50, 80}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x91, 53, 80}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 3840, message: []uint8{0x81, 46, 0}},
+		&MIDIEvent{tick: 3840, message: []uint8{0x81, 50, 0}},
+		&MIDIEvent{tick: 3840, message: []uint8{0x81, 53, 0}},
+		&MIDIEvent{tick: 3840, message: []uint8{0x89, 36, 0}},
+		&MIDIEvent{tick: 3840, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	d := newTestData(480, melody, chords)
+
+	sheet := d.LeadSheet()
+	if want := []string{"C", "A#"}; !reflect.DeepEqual(sheet.Chords, want) {
+		t.Errorf("Chords = %v, want %v", sheet.Chords, want)
+	}
+	want := []LeadNote{
+		{Note{Key: 76, Velocity: 100, Start: 0, End: 960}, 1, 1},
+		{Note{Key: 55, Channel: 1, Velocity: 80, Start: 960, End: 1200}, 1, 3},
+		{Note{Key: 72, Velocity: 100, Start: 1200, End: 2400}, 1, 3.5},
+		{Note{Key: 53, Channel: 1, Velocity: 80, Start: 2400, End: 3840}, 2, 2},
+	}
+	if !reflect.DeepEqual(sheet.Melody, want) {
+		t.Errorf("Melody = %v, want %v", sheet.Melody, want)
+	}
+
+	// Flats follow the key signature.
+	d.At(0).insert(&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x59, 0x02, 0xFF, 0x00}})
+	if got := d.LeadSheet().Chords; got[1] != "Bb" {
+		t.Errorf("Chords = %v, want Bb in bar 2", got)
+	}
+}
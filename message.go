@@ -0,0 +1,225 @@
+package midi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Message is a decoded MIDI message. It is one of NoteOff, NoteOn,
+// PolyAftertouch, ControlChange, ChannelMode, ProgramChange,
+// ChannelPressure, PitchBend, SysEx or MetaEvent.
+type Message interface {
+	isMessage()
+}
+
+// NoteOff represents a note off message. Note that a NoteOn with zero
+// velocity is also commonly used to end a note.
+type NoteOff struct {
+	Channel  int
+	Key      int
+	Velocity int
+}
+
+// NoteOn represents a note on message.
+type NoteOn struct {
+	Channel  int
+	Key      int
+	Velocity int
+}
+
+// PolyAftertouch represents a polyphonic key pressure message.
+type PolyAftertouch struct {
+	Channel  int
+	Key      int
+	Pressure int
+}
+
+// ControlChange represents a control change message for controllers
+// 0-119. Controllers 120-127 are decoded as ChannelMode.
+type ControlChange struct {
+	Channel    int
+	Controller int
+	Value      int
+}
+
+// ChannelModeType identifies a channel mode message. Its value is the
+// controller number used to send it.
+type ChannelModeType int
+
+const (
+	AllSoundOff         ChannelModeType = 120
+	ResetAllControllers ChannelModeType = 121
+	LocalControl        ChannelModeType = 122
+	AllNotesOff         ChannelModeType = 123
+	OmniModeOff         ChannelModeType = 124
+	OmniModeOn          ChannelModeType = 125
+	MonoModeOn          ChannelModeType = 126
+	PolyModeOn          ChannelModeType = 127
+)
+
+var channelModeNames = map[ChannelModeType]string{
+	AllSoundOff:         "All Sound Off",
+	ResetAllControllers: "Reset All Controllers",
+	LocalControl:        "Local Control",
+	AllNotesOff:         "All Notes Off",
+	OmniModeOff:         "Omni Mode Off",
+	OmniModeOn:          "Omni Mode On",
+	MonoModeOn:          "Mono Mode On",
+	PolyModeOn:          "Poly Mode On",
+}
+
+func (t ChannelModeType) String() string {
+	if name, ok := channelModeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("ChannelModeType(%d)", int(t))
+}
+
+// ChannelMode represents a channel mode message (controllers 120-127).
+// Value is the raw data byte; for MonoModeOn it is the number of
+// channels, for LocalControl 0 means off and 127 means on.
+type ChannelMode struct {
+	Channel int
+	Mode    ChannelModeType
+	Value   int
+}
+
+// ProgramChange represents a program change message.
+type ProgramChange struct {
+	Channel int
+	Program int
+}
+
+// ChannelPressure represents a channel pressure (aftertouch) message.
+type ChannelPressure struct {
+	Channel  int
+	Pressure int
+}
+
+// PitchBend represents a pitch bend message. Value ranges from -8192 to
+// 8191, with 0 meaning no bend.
+type PitchBend struct {
+	Channel int
+	Value   int
+}
+
+// SysEx represents a system exclusive event. Status is 0xF0 for a
+// complete message or its first packet and 0xF7 for a continuation or
+// escape sequence. Data excludes the status byte and length prefix.
+type SysEx struct {
+	Status uint8
+	Data   []uint8
+}
+
+// MetaEvent represents a meta event. Data excludes the length prefix.
+type MetaEvent struct {
+	Type uint8
+	Data []uint8
+}
+
+func (NoteOff) isMessage()         {}
+func (NoteOn) isMessage()          {}
+func (PolyAftertouch) isMessage()  {}
+func (ControlChange) isMessage()   {}
+func (ChannelMode) isMessage()     {}
+func (ProgramChange) isMessage()   {}
+func (ChannelPressure) isMessage() {}
+func (PitchBend) isMessage()       {}
+func (SysEx) isMessage()           {}
+func (MetaEvent) isMessage()       {}
+
+// ParseMessage decodes a message as stored in a MIDIEvent, i.e. with its
+// status byte present.
+func ParseMessage(message []uint8) (Message, error) {
+	if len(message) == 0 {
+		return nil, errors.New("empty message")
+	}
+
+	status := message[0]
+	switch {
+	case status == 0xFF:
+		if len(message) < 2 {
+			return nil, errors.New("truncated meta event")
+		}
+		data, err := lengthPrefixed(message[2:])
+		if err != nil {
+			return nil, err
+		}
+		return MetaEvent{Type: message[1], Data: data}, nil
+	case status == 0xF0 || status == 0xF7:
+		data, err := lengthPrefixed(message[1:])
+		if err != nil {
+			return nil, err
+		}
+		return SysEx{Status: status, Data: data}, nil
+	case status < 0x80 || status > 0xF0:
+		return nil, fmt.Errorf("invalid status byte 0x%02X", status)
+	}
+
+	ch := int(status & 0x0F)
+	kind := status & 0xF0
+	want := 3
+	if kind == 0xC0 || kind == 0xD0 {
+		want = 2
+	}
+	if len(message) != want {
+		return nil, fmt.Errorf("invalid length %d for status 0x%02X",
+			len(message), status)
+	}
+
+	switch kind {
+	case 0x80:
+		return NoteOff{Channel: ch, Key: int(message[1]),
+			Velocity: int(message[2])}, nil
+	case 0x90:
+		return NoteOn{Channel: ch, Key: int(message[1]),
+			Velocity: int(message[2])}, nil
+	case 0xA0:
+		return PolyAftertouch{Channel: ch, Key: int(message[1]),
+			Pressure: int(message[2])}, nil
+	case 0xB0:
+		if message[1] >= 120 {
+			return ChannelMode{Channel: ch,
+				Mode: ChannelModeType(message[1]), Value: int(message[2])}, nil
+		}
+		return ControlChange{Channel: ch, Controller: int(message[1]),
+			Value: int(message[2])}, nil
+	case 0xC0:
+		return ProgramChange{Channel: ch, Program: int(message[1])}, nil
+	case 0xD0:
+		return ChannelPressure{Channel: ch, Pressure: int(message[1])}, nil
+	default:
+		value := int(message[2])<<7 | int(message[1])
+		return PitchBend{Channel: ch, Value: value - 8192}, nil
+	}
+}
+
+// lengthPrefixed returns the payload of a variable-length prefixed
+// block, checking that the declared length matches.
+func lengthPrefixed(b []uint8) ([]uint8, error) {
+	length, n, err := decodeVarLen(b)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(b)-n) != length {
+		return nil, fmt.Errorf("declared length %d but %d bytes present",
+			length, len(b)-n)
+	}
+	return b[n:], nil
+}
+
+// decodeVarLen decodes a variable-length quantity from the start of b and
+// returns its value and the number of bytes consumed.
+func decodeVarLen(b []uint8) (uint64, int, error) {
+	var val uint64
+	for i, c := range b {
+		if i >= 4 {
+			break
+		}
+		val = val<<7 | uint64(c&0x7F)
+		if c&0x80 == 0 {
+			return val, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("invalid variable-length quantity")
+}
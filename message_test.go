@@ -0,0 +1,85 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	cases := []struct {
+		message []uint8
+		want    Message
+	}{
+		{[]uint8{0x81, 60, 64}, NoteOff{Channel: 1, Key: 60, Velocity: 64}},
+		{[]uint8{0x92, 61, 100}, NoteOn{Channel: 2, Key: 61, Velocity: 100}},
+		{[]uint8{0xA3, 62, 10}, PolyAftertouch{Channel: 3, Key: 62, Pressure: 10}},
+		{[]uint8{0xB4, 7, 90}, ControlChange{Channel: 4, Controller: 7, Value: 90}},
+		{[]uint8{0xC5, 40}, ProgramChange{Channel: 5, Program: 40}},
+		{[]uint8{0xD6, 33}, ChannelPressure{Channel: 6, Pressure: 33}},
+		{[]uint8{0xE7, 0x00, 0x40}, PitchBend{Channel: 7, Value: 0}},
+		{[]uint8{0xE7, 0x7F, 0x7F}, PitchBend{Channel: 7, Value: 8191}},
+		{[]uint8{0xE7, 0x00, 0x00}, PitchBend{Channel: 7, Value: -8192}},
+		{[]uint8{0xF0, 0x03, 0x7E, 0x7F, 0xF7},
+			SysEx{Status: 0xF0, Data: []uint8{0x7E, 0x7F, 0xF7}}},
+		{[]uint8{0xFF, 0x03, 0x02, 'h', 'i'},
+			MetaEvent{Type: 0x03, Data: []uint8("hi")}},
+	}
+
+	for _, c := range cases {
+		got, err := ParseMessage(c.message)
+		if err != nil {
+			t.Errorf("ParseMessage(% X): %v", c.message, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseMessage(% X) = %#v, want %#v", c.message, got, c.want)
+		}
+	}
+}
+
+func TestParseChannelMode(t *testing.T) {
+	cases := []struct {
+		controller uint8
+		value      uint8
+		mode       ChannelModeType
+	}{
+		{120, 0, AllSoundOff},
+		{121, 0, ResetAllControllers},
+		{122, 127, LocalControl},
+		{123, 0, AllNotesOff},
+		{124, 0, OmniModeOff},
+		{125, 0, OmniModeOn},
+		{126, 1, MonoModeOn},
+		{127, 0, PolyModeOn},
+	}
+
+	for _, c := range cases {
+		got, err := ParseMessage([]uint8{0xB9, c.controller, c.value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ChannelMode{Channel: 9, Mode: c.mode, Value: int(c.value)}
+		if got != want {
+			t.Errorf("controller %d: got %#v, want %#v", c.controller, got, want)
+		}
+	}
+
+	if s := ResetAllControllers.String(); s != "Reset All Controllers" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestParseMessageErrors(t *testing.T) {
+	bad := [][]uint8{
+		{},
+		{0x40, 0x00},
+		{0x90, 60},
+		{0xC0, 1, 2},
+		{0xFF, 0x03, 0x05, 'a'},
+	}
+	for _, message := range bad {
+		if _, err := ParseMessage(message); err == nil {
+			t.Errorf("ParseMessage(% X) should fail", message)
+		}
+	}
+}
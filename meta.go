@@ -0,0 +1,213 @@
+package midi
+
+// isMetaEvent reports whether message is a meta event of the given type.
+func isMetaEvent(message []uint8, typ uint8) bool {
+	return len(message) >= 2 && message[0] == 0xFF && message[1] == typ
+}
+
+// conductorMetaTypes lists the meta events that belong on the first track
+// of a format 1 file.
+var conductorMetaTypes = []uint8{0x51, 0x58, 0x59}
+
+func isConductorEvent(message []uint8) bool {
+	for _, typ := range conductorMetaTypes {
+		if isMetaEvent(message, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeMetaTracks moves every tempo, time signature and key signature
+// event to the first track, as expected by format 1 readers, keeping
+// absolute ticks. If the first track carries channel messages, a new
+// empty first track is inserted to hold them. It does nothing for
+// formats other than 1.
+func (d *MIDIData) NormalizeMetaTracks() {
+	if d.Format != 1 {
+		return
+	}
+
+	if len(d.tracks) == 0 || len(d.tracks[0].Channels()) > 0 {
+		conductor := &MIDITrack{}
+		conductor.appendEndOfTrack()
+		d.tracks = append([]*MIDITrack{conductor}, d.tracks...)
+	}
+
+	conductor := d.tracks[0]
+	for _, t := range d.tracks[1:] {
+		events := t.events[:0]
+		for _, e := range t.events {
+			if isConductorEvent(e.message) {
+				conductor.insert(e)
+				continue
+			}
+			events = append(events, e)
+		}
+		t.events = events
+	}
+}
+
+// hasMeta reports whether the track has a meta event of the given type.
+func (t *MIDITrack) hasMeta(typ uint8) bool {
+	for _, e := range t.events {
+		if isMetaEvent(e.message, typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetaPayload is the data of a meta event at an absolute tick.
+type MetaPayload struct {
+	Tick int64
+	Data []byte // payload without the length prefix
+}
+
+// MetaEvents returns every meta event of the given type in the track, in
+// order. It is the low-level counterpart of the typed accessors such as
+// TextEvents and KeySignatures, meant for types the package doesn't
+// model, e.g. sequencer specific (FF 7F) data. Events whose length prefix
+// doesn't match their size are skipped. The payloads alias the events.
+func (t *MIDITrack) MetaEvents(typ byte) []MetaPayload {
+	var events []MetaPayload
+	for _, e := range t.events {
+		if !isMetaEvent(e.message, typ) {
+			continue
+		}
+		data, err := lengthPrefixed(e.message[2:])
+		if err != nil {
+			continue
+		}
+		events = append(events, MetaPayload{Tick: e.tick, Data: data})
+	}
+	return events
+}
+
+// Marker is a text meta event placed on the timeline, such as a marker or
+// a cue point.
+type Marker struct {
+	Tick    int64
+	Seconds float64
+	Text    string
+	Channel int // channel set by a channel prefix, or -1
+}
+
+// prefixChannels returns the channel that a preceding channel prefix
+// (FF 20) assigns to each meta and system exclusive event of the track.
+// Per the SMF specification a prefix applies until the next channel
+// message or channel prefix. Events outside any prefix are absent.
+func (t *MIDITrack) prefixChannels() map[*MIDIEvent]int {
+	channels := make(map[*MIDIEvent]int)
+	prefix := -1
+	for _, e := range t.events {
+		if _, ok := channelOf(e.message); ok {
+			prefix = -1
+			continue
+		}
+		if len(e.message) == 4 && isMetaEvent(e.message, 0x20) &&
+			e.message[2] == 0x01 && e.message[3] < 16 {
+			prefix = int(e.message[3])
+			continue
+		}
+		if prefix >= 0 {
+			channels[e] = prefix
+		}
+	}
+	return channels
+}
+
+// prefixChannel looks up e in the result of prefixChannels.
+func prefixChannel(channels map[*MIDIEvent]int, e *MIDIEvent) int {
+	if ch, ok := channels[e]; ok {
+		return ch
+	}
+	return -1
+}
+
+// textMetaEvents returns the meta events of the given type across all
+// tracks in tick order, with their times resolved through the tempo map.
+func (d *MIDIData) textMetaEvents(typ uint8) []Marker {
+	var markers []Marker
+	channels := make([]map[*MIDIEvent]int, len(d.tracks))
+	for i, t := range d.tracks {
+		channels[i] = t.prefixChannels()
+	}
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		if !isMetaEvent(e.message, typ) {
+			return true
+		}
+		msg, err := ParseMessage(e.message)
+		if err != nil {
+			return true
+		}
+		markers = append(markers, Marker{
+			Tick:    e.tick,
+			Seconds: d.TickToSeconds(e.tick),
+			Text:    d.tracks[track].decodeText(msg.(MetaEvent).Data),
+			Channel: prefixChannel(channels[track], e),
+		})
+		return true
+	})
+	return markers
+}
+
+// Markers returns the marker (FF 06) events of all tracks in tick order.
+func (d *MIDIData) Markers() []Marker {
+	return d.textMetaEvents(0x06)
+}
+
+// CuePoints returns the cue point (FF 07) events of all tracks in tick
+// order. Cue points mark sync points such as film cues.
+func (d *MIDIData) CuePoints() []Marker {
+	return d.textMetaEvents(0x07)
+}
+
+// NextMarker returns the first marker after the given tick, e.g. to jump
+// to the next section in a player. It reports false if there is none.
+func (d *MIDIData) NextMarker(afterTick int64) (Marker, bool) {
+	for _, m := range d.Markers() {
+		if m.Tick > afterTick {
+			return m, true
+		}
+	}
+	return Marker{}, false
+}
+
+// PrevMarker returns the last marker before the given tick. It reports
+// false if there is none.
+func (d *MIDIData) PrevMarker(beforeTick int64) (Marker, bool) {
+	markers := d.Markers()
+	for i := len(markers) - 1; i >= 0; i-- {
+		if markers[i].Tick < beforeTick {
+			return markers[i], true
+		}
+	}
+	return Marker{}, false
+}
+
+// KeySignature represents a key signature event.
+type KeySignature struct {
+	Count  uint64 // tick
+	Sharps int    // number of sharps, negative for flats
+	Minor  bool
+}
+
+// KeySignatures returns the key signature events of all tracks in tick
+// order. Unlike TimeSignatures, no default is reported when the data
+// has none.
+func (d *MIDIData) KeySignatures() []KeySignature {
+	var keys []KeySignature
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		if len(e.message) == 5 && isMetaEvent(e.message, 0x59) &&
+			e.message[2] == 0x02 {
+			keys = append(keys, KeySignature{
+				Count:  uint64(e.tick),
+				Sharps: int(int8(e.message[3])),
+				Minor:  e.message[4] == 1,
+			})
+		}
+		return true
+	})
+	return keys
+}
@@ -0,0 +1,179 @@
+package midi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeMetaTracks(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 480, message: tempoMessage(90)},
+			&MIDIEvent{tick: 960, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x58, 0x04, 3, 2, 24, 8}},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x03, 0x01, 'b'}},
+			&MIDIEvent{tick: 240, message: []uint8{0x91, 64, 100}},
+			&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+	)
+
+	var before bytes.Buffer
+	if err := Write(&before, d, WriteOptions{NormalizeMetaTracks: true}); err != nil {
+		t.Fatal(err)
+	}
+	if d.Len() != 2 {
+		t.Fatalf("Write must not modify the data")
+	}
+
+	d.NormalizeMetaTracks()
+	if d.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", d.Len())
+	}
+
+	conductor := d.At(0)
+	if conductor.Len() != 3 {
+		t.Fatalf("conductor has %d events, want 3", conductor.Len())
+	}
+	if e := conductor.At(0); e.Tick() != 0 || !isTimeSignatureEvent(e.Message()) {
+		t.Errorf("event 0 = %d % X, want time signature", e.Tick(), e.Message())
+	}
+	if e := conductor.At(1); e.Tick() != 480 || !isTempoEvent(e.Message()) {
+		t.Errorf("event 1 = %d % X, want tempo", e.Tick(), e.Message())
+	}
+	if e := conductor.At(2); e.Tick() != 480 || !isEndOfTrack(e.Message()) {
+		t.Errorf("event 2 = %d % X, want end of track", e.Tick(), e.Message())
+	}
+	if d.At(1).Len() != 3 || d.At(2).Len() != 3 {
+		t.Errorf("channel events must stay on their tracks")
+	}
+
+	var after bytes.Buffer
+	if err := Write(&after, d, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before.Bytes(), after.Bytes()) {
+		t.Errorf("WriteOptions.NormalizeMetaTracks output differs")
+	}
+}
+
+func TestCuePoints(t *testing.T) {
+	conductor := []byte{
+		0x00, 0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40, // 60 bpm
+		0x00, 0xFF, 0x06, 0x05, 'I', 'n', 't', 'r', 'o',
+		0x83, 0x60, 0xFF, 0x07, 0x04, 'H', 'i', 't', '1',
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	notes := []byte{
+		0x00, 0x90, 60, 100,
+		0x8F, 0x00, 0xFF, 0x07, 0x04, 'H', 'i', 't', '2',
+		0x00, 0x80, 60, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+
+	m, err := Read(bytes.NewReader(buildSMF(1, 480, conductor, notes)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+
+	want := []Marker{
+		{Tick: 480, Seconds: 1, Text: "Hit1", Channel: -1},
+		{Tick: 1920, Seconds: 4, Text: "Hit2", Channel: -1},
+	}
+	if got := d.CuePoints(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CuePoints() = %v, want %v", got, want)
+	}
+
+	markers := d.Markers()
+	if len(markers) != 1 || markers[0].Text != "Intro" || markers[0].Tick != 0 {
+		t.Errorf("Markers() = %v", markers)
+	}
+}
+
+func TestNextPrevMarker(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40}},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x06, 0x01, 'A'}},
+			&MIDIEvent{tick: 1920, message: []uint8{0xFF, 0x06, 0x01, 'C'}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x06, 0x01, 'B'}},
+		),
+	)
+
+	m, ok := d.NextMarker(0)
+	if want := (Marker{Tick: 960, Seconds: 2, Text: "B", Channel: -1}); !ok || m != want {
+		t.Errorf("NextMarker(0) = %v, %v, want %v, true", m, ok, want)
+	}
+	if m, ok := d.NextMarker(-1); !ok || m.Text != "A" {
+		t.Errorf("NextMarker(-1) = %v, %v, want A", m, ok)
+	}
+	if m, ok := d.NextMarker(1920); ok {
+		t.Errorf("NextMarker(1920) = %v, want none", m)
+	}
+
+	if m, ok := d.PrevMarker(1920); !ok || m.Text != "B" {
+		t.Errorf("PrevMarker(1920) = %v, %v, want B", m, ok)
+	}
+	if m, ok := d.PrevMarker(5000); !ok || m.Text != "C" || m.Seconds != 4 {
+		t.Errorf("PrevMarker(5000) = %v, %v, want C at 4 seconds", m, ok)
+	}
+	if m, ok := d.PrevMarker(0); ok {
+		t.Errorf("PrevMarker(0) = %v, want none", m)
+	}
+}
+
+func TestChannelPrefix(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x06, 0x01, 'a'}},
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x20, 0x01, 0x03}},
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x06, 0x01, 'b'}},
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x01, 0x01, 'x'}},
+		&MIDIEvent{tick: 10, message: []uint8{0xFF, 0x20, 0x01, 0x05}},
+		&MIDIEvent{tick: 10, message: []uint8{0xFF, 0x06, 0x01, 'c'}},
+		&MIDIEvent{tick: 20, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 30, message: []uint8{0xFF, 0x06, 0x01, 'd'}},
+		&MIDIEvent{tick: 30, message: []uint8{0xFF, 0x01, 0x01, 'y'}},
+	))
+
+	var got []int
+	for _, m := range d.Markers() {
+		got = append(got, m.Channel)
+	}
+	if want := []int{-1, 3, 5, -1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("marker channels = %v, want %v", got, want)
+	}
+
+	texts := d.At(0).TextEvents()
+	if texts[0].Channel != 3 || texts[1].Channel != -1 {
+		t.Errorf("text channels = %d %d, want 3 -1",
+			texts[0].Channel, texts[1].Channel)
+	}
+}
+
+func TestMetaEvents(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x7F, 0x03, 0x00, 0x00, 0x41}},
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x01, 0x01, 'a'}},
+		&MIDIEvent{tick: 10, message: []uint8{0xFF, 0x7F, 0x05, 0x43}}, // bad length
+		&MIDIEvent{tick: 20, message: []uint8{0xFF, 0x7F, 0x00}},
+		&MIDIEvent{tick: 20, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+
+	want := []MetaPayload{
+		{Tick: 0, Data: []byte{0x00, 0x00, 0x41}},
+		{Tick: 20, Data: []byte{}},
+	}
+	if got := track.MetaEvents(0x7F); !reflect.DeepEqual(got, want) {
+		t.Errorf("MetaEvents(0x7F) = %v, want %v", got, want)
+	}
+	if got := track.MetaEvents(0x05); got != nil {
+		t.Errorf("MetaEvents(0x05) = %v, want nil", got)
+	}
+}
@@ -1,13 +1,26 @@
 package midi
 
 import (
-	"fmt"
+	"bytes"
+	"sort"
 )
 
 // MIDIEvent represents a MIDI Event.
 type MIDIEvent struct {
 	tick    int64 // absolute tick
 	message []uint8
+	offset  int64 // byte offset in the source file, or 0 if unknown
+	tie     bool  // note on or off inserted by SplitAt
+}
+
+// NewMIDIEvent returns an event at the given absolute tick. The message
+// must include its status byte; meta and system exclusive events are
+// stored as in a file, including their length prefix.
+func NewMIDIEvent(tick int64, message []uint8) *MIDIEvent {
+	return &MIDIEvent{
+		tick:    tick,
+		message: message,
+	}
 }
 
 func (e *MIDIEvent) Tick() int64 {
@@ -22,16 +35,60 @@ func (e *MIDIEvent) Message() []uint8 {
 	return e.message
 }
 
+// SourceOffset returns the byte offset in the source file at which the
+// event, starting with its delta time, was read. It is only known for
+// events read with ReadOptions.TrackOffsets set.
+func (e *MIDIEvent) SourceOffset() (int64, bool) {
+	return e.offset, e.offset > 0
+}
+
+func (e *MIDIEvent) clone() *MIDIEvent {
+	message := make([]uint8, len(e.message))
+	copy(message, e.message)
+	return &MIDIEvent{
+		tick:    e.tick,
+		message: message,
+		offset:  e.offset,
+		tie:     e.tie,
+	}
+}
+
+// isEndOfTrack reports whether message is an end of track meta event.
+func isEndOfTrack(message []uint8) bool {
+	return len(message) >= 2 && message[0] == 0xFF && message[1] == 0x2F
+}
+
+// channelOf returns the channel of a channel voice message.
+func channelOf(message []uint8) (int, bool) {
+	if len(message) == 0 || message[0] < 0x80 || message[0] >= 0xF0 {
+		return 0, false
+	}
+	return int(message[0] & 0x0F), true
+}
+
 // MIDITrack represents a MIDI track that is composed of MIDI events.
 type MIDITrack struct {
-	Name   string
-	events []*MIDIEvent
+	Name    string
+	events  []*MIDIEvent
+	charset Charset
 }
 
 func (t *MIDITrack) Append(e *MIDIEvent) {
 	t.events = append(t.events, e)
 }
 
+func (t *MIDITrack) clone() *MIDITrack {
+	c := &MIDITrack{
+		Name:    t.Name,
+		events:  make([]*MIDIEvent, len(t.events)),
+		charset: t.charset,
+	}
+	for i, e := range t.events {
+		c.events[i] = e.clone()
+	}
+	return c
+}
+
 func (t *MIDITrack) Len() int {
 	return len(t.events)
 }
@@ -40,6 +97,31 @@ func (t *MIDITrack) At(i int) *MIDIEvent {
 	return t.events[i]
 }
 
+// Equal reports whether t and other hold the same events, comparing ticks
+// and message bytes. Track names are not compared.
+func (t *MIDITrack) Equal(other *MIDITrack) bool {
+	if len(t.events) != len(other.events) {
+		return false
+	}
+	for i, e := range t.events {
+		o := other.events[i]
+		if e.tick != o.tick || !bytes.Equal(e.message, o.message) {
+			return false
+		}
+	}
+	return true
+}
+
+// DeltaAt returns the delta time of the i-th event, i.e. the ticks since
+// the previous event, or since tick 0 for the first event. Like At, it
+// panics if i is out of range.
+func (t *MIDITrack) DeltaAt(i int) int64 {
+	if i == 0 {
+		return t.events[0].tick
+	}
+	return t.events[i].tick - t.events[i-1].tick
+}
+
 // MIDIData represents a MIDI data that is composed of MIDI tracks.
 type MIDIData struct {
 	Name          string
@@ -50,6 +132,19 @@ type MIDIData struct {
 	timeSigEvents []TimeSignature
 }
 
+func (d *MIDIData) clone() *MIDIData {
+	c := &MIDIData{
+		Name:     d.Name,
+		Format:   d.Format,
+		Division: d.Division,
+	}
+	for _, t := range d.tracks {
+		c.Append(t.clone())
+	}
+	c.updateMaps()
+	return c
+}
+
 func (d *MIDIData) Append(track *MIDITrack) {
 	d.tracks = append(d.tracks, track)
 }
@@ -70,26 +165,117 @@ func BuildMIDIDataFromMIDIFile(m *MIDIFile) *MIDIData {
 
 	numTracks := m.NumTracks
 	for track := 0; track < numTracks; track++ {
-		t := &MIDITrack{}
+		d.Append(buildTrack(m, track))
+	}
+	d.updateMaps()
 
-		var accumulateTicks int64 = 0
+	return d
+}
 
-		for {
-			tick, rawEvent := m.NextEvent(track)
-			if rawEvent == nil {
-				break
-			}
-			accumulateTicks += int64(tick)
-			event := &MIDIEvent{
-				tick:    accumulateTicks,
-				message: rawEvent,
+// buildTrack reads all events of a track from the beginning. The track is
+// named after its first track name event at tick 0, if any. A track left
+// out by ReadOptions.Tracks is returned empty, except that the conductor
+// track of a format 0 or 1 file keeps its tempo, time signature and end
+// of track events so that timing stays correct.
+func buildTrack(m *MIDIFile, track int) *MIDITrack {
+	t := &MIDITrack{charset: m.options.Charset}
+	conductorOnly := !m.wantTrack(track)
+	if conductorOnly && (track != 0 || m.Format == 2) {
+		return t
+	}
+	m.RewindTrack(track)
+
+	var accumulateTicks int64 = 0
+
+	for {
+		offset := m.trackPointers[track]
+		tick, rawEvent := m.NextEvent(track)
+		if rawEvent == nil {
+			break
+		}
+		accumulateTicks += int64(tick)
+		if conductorOnly && !isMetaEvent(rawEvent, 0x51) &&
+			!isMetaEvent(rawEvent, 0x58) && !isEndOfTrack(rawEvent) {
+			continue
+		}
+		event := &MIDIEvent{
+			tick:    accumulateTicks,
+			message: rawEvent,
+		}
+		if m.options.TrackOffsets {
+			event.offset = offset
+		}
+		if t.Name == "" && accumulateTicks == 0 && isMetaEvent(rawEvent, 0x03) {
+			if data, err := lengthPrefixed(rawEvent[2:]); err == nil {
+				t.Name = t.decodeText(data)
 			}
-			fmt.Println(*event, event.Len())
+		}
+		t.Append(event)
+	}
+
+	return t
+}
+
+// Sequences returns the independent sequences of the file. In format 2
+// the tracks are temporally unrelated patterns, each with its own tempo
+// map, so every track becomes a standalone format 0 MIDIData. Files of
+// other formats hold a single sequence, returned as one MIDIData.
+func (m *MIDIFile) Sequences() []*MIDIData {
+	if m.Format != 2 {
+		return []*MIDIData{BuildMIDIDataFromMIDIFile(m)}
+	}
 
-			t.Append(event)
+	sequences := make([]*MIDIData, m.NumTracks)
+	for track := range sequences {
+		d := &MIDIData{
+			Division: m.Division,
+			Format:   0,
 		}
-		d.Append(t)
+		d.Append(buildTrack(m, track))
+		d.updateMaps()
+		sequences[track] = d
 	}
+	return sequences
+}
 
-	return d
+// insert adds e after any events at the same tick, keeping the track
+// sorted and the end of track event last.
+func (t *MIDITrack) insert(e *MIDIEvent) {
+	i := sort.Search(len(t.events), func(i int) bool {
+		return t.events[i].tick > e.tick || isEndOfTrack(t.events[i].message)
+	})
+	t.events = append(t.events, nil)
+	copy(t.events[i+1:], t.events[i:])
+	t.events[i] = e
+
+	if n := len(t.events); isEndOfTrack(t.events[n-1].message) &&
+		t.events[n-1].tick < e.tick {
+		t.events[n-1].tick = e.tick
+	}
+}
+
+// sortEvents restores tick order after events have been moved. At equal
+// ticks note offs come first so that a note ending where another starts
+// doesn't cut it off, and the end of track event is moved to the end.
+func (t *MIDITrack) sortEvents() {
+	isNoteOff := func(e *MIDIEvent) bool {
+		_, _, on, ok := noteEvent(e.message)
+		return ok && !on
+	}
+	sort.SliceStable(t.events, func(i, j int) bool {
+		a, b := t.events[i], t.events[j]
+		if isEndOfTrack(a.message) != isEndOfTrack(b.message) {
+			return isEndOfTrack(b.message)
+		}
+		if a.tick != b.tick {
+			return a.tick < b.tick
+		}
+		return isNoteOff(a) && !isNoteOff(b)
+	})
+
+	n := len(t.events)
+	if n > 1 && isEndOfTrack(t.events[n-1].message) &&
+		t.events[n-1].tick < t.events[n-2].tick {
+		t.events[n-1].tick = t.events[n-2].tick
+	}
 }
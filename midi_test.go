@@ -0,0 +1,105 @@
+package midi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeltaAt(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 10, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 10, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 250, message: []uint8{0x80, 60, 0}},
+	)
+	for i, want := range []int64{10, 0, 240} {
+		if got := track.DeltaAt(i); got != want {
+			t.Errorf("DeltaAt(%d) = %d, want %d", i, got, want)
+		}
+	}
+
+	single := newTestTrack(&MIDIEvent{tick: 5, message: []uint8{0xFF, 0x2F, 0x00}})
+	if got := single.DeltaAt(0); got != 5 {
+		t.Errorf("DeltaAt(0) = %d, want 5", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("DeltaAt on an empty track should panic")
+		}
+	}()
+	newTestTrack().DeltaAt(0)
+}
+
+func TestSequences(t *testing.T) {
+	first := []byte{
+		0x00, 0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20, // 120 bpm
+		0x00, 0x90, 60, 100,
+		0x83, 0x60, 0x80, 60, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	second := []byte{
+		0x00, 0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40, // 60 bpm
+		0x00, 0x99, 36, 100,
+		0x87, 0x40, 0x89, 36, 0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+
+	m, err := Read(bytes.NewReader(buildSMF(2, 480, first, second)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Building the whole file first must not affect the sequences.
+	BuildMIDIDataFromMIDIFile(m)
+
+	seqs := m.Sequences()
+	if len(seqs) != 2 {
+		t.Fatalf("got %d sequences, want 2", len(seqs))
+	}
+	for i, want := range []float64{0.5, 2} {
+		s := seqs[i]
+		if s.Format != 0 || s.Len() != 1 {
+			t.Errorf("sequence %d: format %d with %d tracks", i, s.Format, s.Len())
+		}
+		if got := s.Duration(); got != want {
+			t.Errorf("sequence %d: Duration() = %f, want %f", i, got, want)
+		}
+	}
+
+	m, err = ReadMIDI("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seqs := m.Sequences(); len(seqs) != 1 || seqs[0].Len() != 2 {
+		t.Errorf("format 1 file should be a single sequence")
+	}
+}
+
+func TestSourceOffset(t *testing.T) {
+	track := []byte{0x00, 0x90, 0x3C, 0x64, 0x60, 0x80, 0x3C, 0x00}
+	track = append(track, endOfTrack...)
+	data := buildSMF(0, 480, track)
+
+	m, err := ReadWithOptions(bytes.NewReader(data),
+		ReadOptions{TrackOffsets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+	for i, want := range []int64{22, 26, 30} {
+		got, ok := d.At(0).At(i).SourceOffset()
+		if !ok || got != want {
+			t.Errorf("event %d: SourceOffset() = %d, %v, want %d, true",
+				i, got, ok, want)
+		}
+	}
+
+	m, err = Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = BuildMIDIDataFromMIDIFile(m)
+	if _, ok := d.At(0).At(0).SourceOffset(); ok {
+		t.Error("SourceOffset() known without TrackOffsets")
+	}
+}
@@ -0,0 +1,264 @@
+package midi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// xmlSegment is a chord of keys (or a rest, if keys is empty) between two
+// ticks.
+type xmlSegment struct {
+	keys       []int
+	start, end int64
+}
+
+// xmlNoteTypes names the note values, with the number of dots, for
+// lengths in 32nd notes.
+var xmlNoteTypes = map[int64]struct {
+	name string
+	dots int
+}{
+	1: {"32nd", 0}, 2: {"16th", 0}, 3: {"16th", 1}, 4: {"eighth", 0},
+	6: {"eighth", 1}, 8: {"quarter", 0}, 12: {"quarter", 1},
+	16: {"half", 0}, 24: {"half", 1}, 32: {"whole", 0}, 48: {"whole", 1},
+}
+
+// ToMusicXML writes the data as a MusicXML 3.1 partwise score, a skeleton
+// for notation programs to refine. Every track with notes becomes a part
+// named after the track. Divisions are the ticks per quarter note of the
+// data, so durations are exact. Measures follow the time signature map,
+// and the key is taken from the first key signature. Each part is
+// written as a single voice: notes starting together form a chord
+// lasting until its longest note ends or the next note starts, gaps are
+// filled with rests, and notes crossing bar lines are tied.
+func (d *MIDIData) ToMusicXML(w io.Writer) error {
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return errors.New("MusicXML export requires metrical division")
+	}
+	division := int64(d.Division & 0x7FFF)
+
+	type part struct {
+		name  string
+		notes []Note
+	}
+	var parts []part
+	end := d.LastTick()
+	for i, t := range d.tracks {
+		notes := t.Notes()
+		if len(notes) == 0 {
+			continue
+		}
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("Track %d", i+1)
+		}
+		parts = append(parts, part{name, notes})
+		for _, n := range notes {
+			if n.End > end {
+				end = n.End
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		return errors.New("no notes to export")
+	}
+
+	// Measure boundaries, the last measure running to its full length.
+	lines, err := d.barLines(end)
+	if err != nil {
+		return err
+	}
+	starts := append([]int64{0}, lines...)
+	sigs := d.TimeSignatures()
+	sigAt := func(tick int64) TimeSignature {
+		i := sort.Search(len(sigs), func(i int) bool {
+			return int64(sigs[i].Count) > tick
+		})
+		return sigs[i-1]
+	}
+	last := starts[len(starts)-1]
+	bounds := append(starts, last+d.barLength(sigAt(last)))
+
+	key := KeySignature{}
+	if keys := d.KeySignatures(); len(keys) > 0 {
+		key = keys[0]
+	}
+	mode := "major"
+	if key.Minor {
+		mode = "minor"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 3.1 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">
+<score-partwise version="3.1">
+`)
+	if d.Name != "" {
+		buf.WriteString("  <work><work-title>")
+		xml.EscapeText(&buf, []byte(d.Name))
+		buf.WriteString("</work-title></work>\n")
+	}
+	buf.WriteString("  <part-list>\n")
+	for i, p := range parts {
+		fmt.Fprintf(&buf, "    <score-part id=\"P%d\"><part-name>", i+1)
+		xml.EscapeText(&buf, []byte(p.name))
+		buf.WriteString("</part-name></score-part>\n")
+	}
+	buf.WriteString("  </part-list>\n")
+
+	for i, p := range parts {
+		segments := xmlSegments(p.notes)
+		sum := 0
+		for _, n := range p.notes {
+			sum += n.Key
+		}
+		clef := "<sign>G</sign><line>2</line>"
+		if sum < 60*len(p.notes) {
+			clef = "<sign>F</sign><line>4</line>"
+		}
+
+		fmt.Fprintf(&buf, "  <part id=\"P%d\">\n", i+1)
+		next := 0
+		var prev TimeSignature
+		for m := 0; m+1 < len(bounds); m++ {
+			ms, me := bounds[m], bounds[m+1]
+			fmt.Fprintf(&buf, "    <measure number=\"%d\">\n", m+1)
+			ts := sigAt(ms)
+			if m > 0 {
+				prev = sigAt(bounds[m-1])
+			}
+			switch {
+			case m == 0:
+				fmt.Fprintf(&buf, "      <attributes><divisions>%d</divisions>"+
+					"<key><fifths>%d</fifths><mode>%s</mode></key>"+
+					"<time><beats>%d</beats><beat-type>%d</beat-type></time>"+
+					"<clef>%s</clef></attributes>\n",
+					division, key.Sharps, mode, ts.BeatPerBar, ts.BeatUnit, clef)
+			case prev.BeatPerBar != ts.BeatPerBar || prev.BeatUnit != ts.BeatUnit:
+				fmt.Fprintf(&buf, "      <attributes><time><beats>%d</beats>"+
+					"<beat-type>%d</beat-type></time></attributes>\n",
+					ts.BeatPerBar, ts.BeatUnit)
+			}
+
+			for next < len(segments) && segments[next].end <= ms {
+				next++
+			}
+			pos := ms
+			for j := next; j < len(segments) && segments[j].start < me; j++ {
+				s := segments[j]
+				start, stop := s.start, s.end
+				if start < ms {
+					start = ms
+				}
+				if stop > me {
+					stop = me
+				}
+				if start > pos {
+					writeXMLNote(&buf, nil, pos, start, false, false, key.Sharps, division)
+				}
+				writeXMLNote(&buf, s.keys, start, stop, s.start < ms, s.end > me,
+					key.Sharps, division)
+				pos = stop
+			}
+			switch {
+			case pos == ms:
+				fmt.Fprintf(&buf, "      <note><rest measure=\"yes\"/>"+
+					"<duration>%d</duration><voice>1</voice></note>\n", me-ms)
+			case pos < me:
+				writeXMLNote(&buf, nil, pos, me, false, false, key.Sharps, division)
+			}
+			buf.WriteString("    </measure>\n")
+		}
+		buf.WriteString("  </part>\n")
+	}
+	buf.WriteString("</score-partwise>\n")
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// xmlSegments reduces notes to a single line of chords.
+func xmlSegments(notes []Note) []xmlSegment {
+	sort.SliceStable(notes, func(i, j int) bool {
+		return notes[i].Start < notes[j].Start
+	})
+	var segments []xmlSegment
+	for i := 0; i < len(notes); {
+		s := xmlSegment{start: notes[i].Start}
+		seen := make(map[int]bool)
+		for ; i < len(notes) && notes[i].Start == s.start; i++ {
+			if !seen[notes[i].Key] {
+				seen[notes[i].Key] = true
+				s.keys = append(s.keys, notes[i].Key)
+			}
+			if notes[i].End > s.end {
+				s.end = notes[i].End
+			}
+		}
+		if i < len(notes) && notes[i].Start < s.end {
+			s.end = notes[i].Start
+		}
+		sort.Ints(s.keys)
+		segments = append(segments, s)
+	}
+	return segments
+}
+
+// writeXMLNote writes a chord of keys, or a rest if there are none, from
+// start to end, tied to the previous or next chord as requested.
+func writeXMLNote(buf *bytes.Buffer, keys []int, start, end int64,
+	tieStop, tieStart bool, sharps int, division int64) {
+	duration := end - start
+	var value string
+	if n := duration * 8; n%division == 0 {
+		if t, ok := xmlNoteTypes[n/division]; ok {
+			value = "<type>" + t.name + "</type>"
+			for i := 0; i < t.dots; i++ {
+				value += "<dot/>"
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		fmt.Fprintf(buf, "      <note><rest/><duration>%d</duration>"+
+			"<voice>1</voice>%s</note>\n", duration, value)
+		return
+	}
+
+	for i, k := range keys {
+		buf.WriteString("      <note>")
+		if i > 0 {
+			buf.WriteString("<chord/>")
+		}
+		letter, alter, octave := spellKey(k, sharps < 0)
+		fmt.Fprintf(buf, "<pitch><step>%c</step>", "CDEFGAB"[letter])
+		if alter != 0 {
+			fmt.Fprintf(buf, "<alter>%d</alter>", alter)
+		}
+		fmt.Fprintf(buf, "<octave>%d</octave></pitch><duration>%d</duration>",
+			octave, duration)
+		if tieStop {
+			buf.WriteString(`<tie type="stop"/>`)
+		}
+		if tieStart {
+			buf.WriteString(`<tie type="start"/>`)
+		}
+		buf.WriteString("<voice>1</voice>" + value)
+		if tieStop || tieStart {
+			buf.WriteString("<notations>")
+			if tieStop {
+				buf.WriteString(`<tied type="stop"/>`)
+			}
+			if tieStart {
+				buf.WriteString(`<tied type="start"/>`)
+			}
+			buf.WriteString("</notations>")
+		}
+		buf.WriteString("</note>\n")
+	}
+}
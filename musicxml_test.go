@@ -0,0 +1,61 @@
+package midi
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestToMusicXML(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 67, 100}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 67, 0}},
+		&MIDIEvent{tick: 1440, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 2400, message: []uint8{0x80, 62, 0}},
+		&MIDIEvent{tick: 2400, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	d := newTestData(480, track)
+
+	var buf bytes.Buffer
+	if err := d.ToMusicXML(&buf); err != nil {
+		t.Fatalf("ToMusicXML() error: %v", err)
+	}
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		if _, err := dec.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("output isn't well-formed: %v\n%s", err, buf.String())
+		}
+	}
+
+	out := buf.String()
+	if n := strings.Count(out, "<measure "); n != 2 {
+		t.Errorf("got %d measures, want 2", n)
+	}
+	for _, s := range []string{
+		"<divisions>480</divisions>",
+		"<chord/>",
+		"<step>G</step>",
+		`<tie type="start"/>`,
+		`<tie type="stop"/>`,
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("output doesn't contain %s", s)
+		}
+	}
+
+	empty := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+	if err := empty.ToMusicXML(ioutil.Discard); err == nil {
+		t.Error("ToMusicXML() without notes succeeded")
+	}
+}
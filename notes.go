@@ -0,0 +1,315 @@
+package midi
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sort"
+)
+
+// Note is a note with its start and end ticks.
+type Note struct {
+	Channel  int
+	Key      int
+	Velocity int
+	Start    int64
+	End      int64
+}
+
+// Duration returns the length of the note in ticks.
+func (n Note) Duration() int64 {
+	return n.End - n.Start
+}
+
+// notePair links the note on and note off events of a note. off is nil
+// for a note that is still sounding at the end of the track.
+type notePair struct {
+	on, off *MIDIEvent
+}
+
+// notePairs pairs note ons with note offs on the same channel and key in
+// first-in first-out order. Pairs are returned in note on order.
+func (t *MIDITrack) notePairs() []notePair {
+	var pairs []notePair
+	active := make(map[[2]int][]int)
+	for _, e := range t.events {
+		ch, key, on, ok := noteEvent(e.message)
+		if !ok {
+			continue
+		}
+		k := [2]int{ch, key}
+		if on {
+			active[k] = append(active[k], len(pairs))
+			pairs = append(pairs, notePair{on: e})
+			continue
+		}
+		if len(active[k]) == 0 {
+			continue
+		}
+		pairs[active[k][0]].off = e
+		active[k] = active[k][1:]
+	}
+	return pairs
+}
+
+// lastTick returns the tick of the last event of the track.
+func (t *MIDITrack) lastTick() int64 {
+	if len(t.events) == 0 {
+		return 0
+	}
+	return t.events[len(t.events)-1].tick
+}
+
+// Notes returns the notes of the track in note on order. Note ons are
+// matched with the earliest unmatched note off (or note on with zero
+// velocity) on the same channel and key. Notes that are never released
+// end at the last event of the track.
+func (t *MIDITrack) Notes() []Note {
+	pairs := t.notePairs()
+	notes := make([]Note, len(pairs))
+	end := t.lastTick()
+	for i, p := range pairs {
+		notes[i] = Note{
+			Channel:  int(p.on.message[0] & 0x0F),
+			Key:      int(p.on.message[1]),
+			Velocity: int(p.on.message[2]),
+			Start:    p.on.tick,
+			End:      end,
+		}
+		if p.off != nil {
+			notes[i].End = p.off.tick
+		}
+	}
+	return notes
+}
+
+// HangingNotes returns the notes of the track that are never released,
+// in note on order. Their End is the last tick of the track.
+func (t *MIDITrack) HangingNotes() []Note {
+	var hanging []Note
+	notes := t.Notes()
+	for i, p := range t.notePairs() {
+		if p.off == nil {
+			hanging = append(hanging, notes[i])
+		}
+	}
+	return hanging
+}
+
+// RemoveOrphanNoteOffs deletes note offs (including note ons with zero
+// velocity) that don't release a sounding note on the same channel and
+// key, and returns how many were removed.
+func (t *MIDITrack) RemoveOrphanNoteOffs() int {
+	active := make(map[[2]int]int)
+	events := t.events[:0]
+	removed := 0
+	for _, e := range t.events {
+		if ch, key, on, ok := noteEvent(e.message); ok {
+			k := [2]int{ch, key}
+			if on {
+				active[k]++
+			} else if active[k] == 0 {
+				removed++
+				continue
+			} else {
+				active[k]--
+			}
+		}
+		events = append(events, e)
+	}
+	t.events = events
+	return removed
+}
+
+// IsMonophonic reports whether no two notes of the track sound at the
+// same time, on any channel. It also returns the number of notes that
+// start while an earlier note is still sounding, the reason for a false
+// result.
+func (t *MIDITrack) IsMonophonic() (bool, int) {
+	overlaps := 0
+	var end int64
+	for i, n := range t.Notes() {
+		if i > 0 && n.Start < end {
+			overlaps++
+		}
+		if n.End > end {
+			end = n.End
+		}
+	}
+	return overlaps == 0, overlaps
+}
+
+// Phrase is a span of a track in which notes follow each other without
+// long rests.
+type Phrase struct {
+	StartTick int64
+	EndTick   int64
+}
+
+// Phrases groups the notes of the track into phrases, starting a new
+// phrase wherever a rest of at least restTicks separates two notes.
+// A rest is a span in which no note of the track sounds on any channel,
+// so in a polyphonic track a phrase only ends where every voice is
+// silent. To analyse a single channel, move its events to a track of
+// their own first.
+func (t *MIDITrack) Phrases(restTicks int64) []Phrase {
+	var phrases []Phrase
+	for _, n := range t.Notes() {
+		if k := len(phrases) - 1; k >= 0 && n.Start-phrases[k].EndTick < restTicks {
+			if n.End > phrases[k].EndTick {
+				phrases[k].EndTick = n.End
+			}
+			continue
+		}
+		phrases = append(phrases, Phrase{StartTick: n.Start, EndTick: n.End})
+	}
+	return phrases
+}
+
+// Skyline returns the highest sounding note at each point in time, the
+// simple "skyline" melody extraction heuristic. A note is split where a
+// higher note enters, and a lower note that is still sounding resumes
+// where the note above it ends. Notes are returned in time order and
+// never overlap.
+func (t *MIDITrack) Skyline() []Note {
+	return t.outerVoice(func(a, b int) bool { return a > b })
+}
+
+// Bassline returns the lowest sounding note at each point in time, the
+// counterpart of Skyline. A note is split where a lower note enters, and
+// a higher note that is still sounding resumes where the note below it
+// ends. Where no note sounds there is a gap between the returned notes.
+func (t *MIDITrack) Bassline() []Note {
+	return t.outerVoice(func(a, b int) bool { return a < b })
+}
+
+// outerVoice sweeps over the note boundaries of the track and picks, in
+// each span, the sounding note whose key is preferred over all others by
+// better.
+func (t *MIDITrack) outerVoice(better func(a, b int) bool) []Note {
+	notes := t.Notes()
+	var bounds []int64
+	for _, n := range notes {
+		bounds = append(bounds, n.Start, n.End)
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	bounds = dedupTicks(bounds)
+
+	var line []Note
+	var active []int
+	top, next := -1, 0
+	for i, tick := range bounds {
+		for next < len(notes) && notes[next].Start <= tick {
+			active = append(active, next)
+			next++
+		}
+		rest := active[:0]
+		for _, j := range active {
+			if notes[j].End > tick {
+				rest = append(rest, j)
+			}
+		}
+		active = rest
+		if i+1 == len(bounds) {
+			break
+		}
+
+		prev := top
+		top = -1
+		for _, j := range active {
+			if top < 0 || better(notes[j].Key, notes[top].Key) {
+				top = j
+			}
+		}
+		switch {
+		case top < 0:
+		case top == prev:
+			line[len(line)-1].End = bounds[i+1]
+		default:
+			n := notes[top]
+			n.Start, n.End = tick, bounds[i+1]
+			line = append(line, n)
+		}
+	}
+	return line
+}
+
+// MusicalHash returns a hex encoded SHA-256 hash of the notes of the
+// data that ignores how they are stored: tracks are merged as for format
+// 0 before notes are paired, and only the channel, key, start and length
+// of each note are hashed, in a canonical order. Ticks are reduced
+// together with the division by their greatest common divisor, so the
+// same music written with a multiple of the metrical division hashes the
+// same.
+// Velocities, meta events and the tempo map are not part of the hash.
+func (d *MIDIData) MusicalHash() string {
+	notes := d.ToFormat0().At(0).Notes()
+	sort.Slice(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		if a.Channel != b.Channel {
+			return a.Channel < b.Channel
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.End < b.End
+	})
+
+	division, g := int64(d.Division), int64(1)
+	if d.Division&0x8000 == 0 && d.Division > 0 {
+		g = division
+		for _, n := range notes {
+			g = gcd(gcd(g, n.Start), n.Duration())
+		}
+		division /= g
+	}
+
+	h := sha256.New()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(division))
+	h.Write(buf[:])
+	for _, n := range notes {
+		h.Write([]byte{byte(n.Channel), byte(n.Key)})
+		binary.BigEndian.PutUint64(buf[:], uint64(n.Start/g))
+		h.Write(buf[:])
+		binary.BigEndian.PutUint64(buf[:], uint64(n.Duration()/g))
+		h.Write(buf[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// gcd returns the greatest common divisor of a and b, or the other value
+// if one is 0.
+func gcd(a, b int64) int64 {
+	if a < 0 {
+		a = -a
+	}
+	if b < 0 {
+		b = -b
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// ClampNoteDurations shortens every note longer than maxTicks by moving
+// its note off, e.g. to repair notes whose note off was lost in a
+// recording, and returns the number of notes shortened. Use
+// (*MIDIData).BeatsToTicks to give the limit in beats.
+func (t *MIDITrack) ClampNoteDurations(maxTicks int64) int {
+	clamped := 0
+	for _, p := range t.notePairs() {
+		if p.off != nil && p.off.tick-p.on.tick > maxTicks {
+			p.off.tick = p.on.tick + maxTicks
+			clamped++
+		}
+	}
+	if clamped > 0 {
+		t.sortEvents()
+	}
+	return clamped
+}
@@ -0,0 +1,226 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotes(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 60, 90}},
+		&MIDIEvent{tick: 100, message: []uint8{0x90, 60, 80}},
+		&MIDIEvent{tick: 200, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 300, message: []uint8{0x90, 60, 0}},
+		&MIDIEvent{tick: 400, message: []uint8{0x90, 64, 70}},
+		&MIDIEvent{tick: 500, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+
+	want := []Note{
+		{Channel: 0, Key: 60, Velocity: 100, Start: 0, End: 200},
+		{Channel: 1, Key: 60, Velocity: 90, Start: 0, End: 500},
+		{Channel: 0, Key: 60, Velocity: 80, Start: 100, End: 300},
+		{Channel: 0, Key: 64, Velocity: 70, Start: 400, End: 500},
+	}
+	if got := track.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %v, want %v", got, want)
+	}
+}
+
+func TestRemoveOrphanNoteOffs(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 100, message: []uint8{0x81, 60, 0}},
+		&MIDIEvent{tick: 100, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 200, message: []uint8{0x90, 60, 0}},
+		&MIDIEvent{tick: 300, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+
+	if n := track.RemoveOrphanNoteOffs(); n != 3 {
+		t.Errorf("removed %d events, want 3", n)
+	}
+	if track.Len() != 3 {
+		t.Fatalf("%d events left, want 3", track.Len())
+	}
+	if e := track.At(1); e.Tick() != 100 || e.Message()[0] != 0x80 {
+		t.Errorf("kept the wrong note off: %d % X", e.Tick(), e.Message())
+	}
+}
+
+func TestPhrases(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x91, 48, 100}},
+		&MIDIEvent{tick: 400, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 900, message: []uint8{0x81, 48, 0}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 62, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 2400, message: []uint8{0x80, 64, 0}},
+	)
+
+	want := []Phrase{{0, 960}, {1920, 2400}}
+	if got := track.Phrases(240); !reflect.DeepEqual(got, want) {
+		t.Errorf("Phrases(240) = %v, want %v", got, want)
+	}
+	want = []Phrase{{0, 2400}}
+	if got := track.Phrases(961); !reflect.DeepEqual(got, want) {
+		t.Errorf("Phrases(961) = %v, want %v", got, want)
+	}
+}
+
+func TestSkyline(t *testing.T) {
+	// A four-part cadence whose soprano moves E-D-C over the lower voices,
+	// with the soprano resting while the alto holds.
+	var events []*MIDIEvent
+	note := func(start, end int64, key uint8) {
+		events = append(events,
+			&MIDIEvent{tick: start, message: []uint8{0x90, key, 100}},
+			&MIDIEvent{tick: end, message: []uint8{0x80, key, 0}})
+	}
+	note(0, 480, 64)     // soprano E4
+	note(480, 960, 62)   // soprano D4
+	note(1200, 1440, 60) // soprano C4
+	note(0, 1440, 55)    // alto G3 held
+	note(0, 960, 48)     // tenor C3
+	note(0, 1440, 36)    // bass C2
+	track := newTestTrack(events...)
+	track.sortEvents()
+
+	want := []Note{
+		{Key: 64, Velocity: 100, Start: 0, End: 480},
+		{Key: 62, Velocity: 100, Start: 480, End: 960},
+		{Key: 55, Velocity: 100, Start: 960, End: 1200},
+		{Key: 60, Velocity: 100, Start: 1200, End: 1440},
+	}
+	if got := track.Skyline(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Skyline() = %v, want %v", got, want)
+	}
+}
+
+func TestBassline(t *testing.T) {
+	// A walking bass under a held melody, with a rest in both voices and
+	// the melody sounding alone while the bass drops out.
+	var events []*MIDIEvent
+	note := func(start, end int64, key uint8) {
+		events = append(events,
+			&MIDIEvent{tick: start, message: []uint8{0x90, key, 100}},
+			&MIDIEvent{tick: end, message: []uint8{0x80, key, 0}})
+	}
+	note(0, 960, 64)     // melody E4
+	note(1200, 1440, 65) // melody F4
+	note(0, 480, 36)     // bass C2
+	note(480, 840, 43)   // bass G2
+	note(1200, 1440, 41) // bass F2
+	track := newTestTrack(events...)
+	track.sortEvents()
+
+	want := []Note{
+		{Key: 36, Velocity: 100, Start: 0, End: 480},
+		{Key: 43, Velocity: 100, Start: 480, End: 840},
+		{Key: 64, Velocity: 100, Start: 840, End: 960},
+		{Key: 41, Velocity: 100, Start: 1200, End: 1440},
+	}
+	if got := track.Bassline(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Bassline() = %v, want %v", got, want)
+	}
+}
+
+func TestHangingNotes(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 64, 90}},
+		&MIDIEvent{tick: 240, message: []uint8{0x90, 60, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0xFF, 0x2F, 0x00}},
+	)
+	want := []Note{{Key: 64, Velocity: 90, Start: 0, End: 480}}
+	if got := track.HangingNotes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("HangingNotes() = %v, want %v", got, want)
+	}
+}
+
+func TestMusicalHash(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x03, 0x01, 'a'}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 240, message: []uint8{0x91, 64, 100}},
+			&MIDIEvent{tick: 960, message: []uint8{0x91, 64, 0}},
+		),
+	)
+	hash := d.MusicalHash()
+
+	// Same notes in one track at twice the division, with other
+	// velocities and without the meta events.
+	other := newTestData(960, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 1}},
+		&MIDIEvent{tick: 480, message: []uint8{0x91, 64, 2}},
+		&MIDIEvent{tick: 960, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 1920, message: []uint8{0x81, 64, 0}},
+	))
+	other.Format = 0
+	if got := other.MusicalHash(); got != hash {
+		t.Errorf("MusicalHash() = %s, want %s", got, hash)
+	}
+
+	other.At(0).Transpose(1)
+	if got := other.MusicalHash(); got == hash {
+		t.Error("MusicalHash() unchanged after transposing")
+	}
+}
+
+func TestClampNoteDurations(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 96000, message: []uint8{0x80, 60, 0}},
+	))
+	max := d.BeatsToTicks(4)
+	if max != 1920 {
+		t.Fatalf("BeatsToTicks(4) = %d, want 1920", max)
+	}
+
+	track := d.At(0)
+	if n := track.ClampNoteDurations(max); n != 1 {
+		t.Errorf("ClampNoteDurations() = %d, want 1", n)
+	}
+	want := []Note{
+		{Key: 60, Velocity: 100, Start: 0, End: 1920},
+		{Key: 64, Velocity: 100, Start: 0, End: 480},
+	}
+	if got := track.Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Notes() = %v, want %v", got, want)
+	}
+}
+
+func TestIsMonophonic(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 240, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 240, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 62, 0}},
+	)
+	if ok, overlaps := track.IsMonophonic(); !ok || overlaps != 0 {
+		t.Errorf("IsMonophonic() = %v, %d, want true, 0", ok, overlaps)
+	}
+
+	track = newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 120, message: []uint8{0x90, 67, 100}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 64, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 67, 0}},
+	)
+	if ok, overlaps := track.IsMonophonic(); ok || overlaps != 2 {
+		t.Errorf("IsMonophonic() = %v, %d, want false, 2", ok, overlaps)
+	}
+}
@@ -0,0 +1,124 @@
+package midi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Playlist is an ordered list of MIDI data read from files.
+type Playlist struct {
+	Filenames []string
+	Items     []*MIDIData
+
+	position int
+}
+
+// FileError records a failure to read a single file of a playlist.
+type FileError struct {
+	Filename string
+	Err      error
+}
+
+func (e *FileError) Error() string {
+	return e.Filename + ": " + e.Err.Error()
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// PlaylistError is returned by ReadPlaylist when some of the files could
+// not be read.
+type PlaylistError struct {
+	Errors []*FileError
+}
+
+func (e *PlaylistError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to read %d file(s): %s", len(e.Errors),
+		strings.Join(msgs, "; "))
+}
+
+// ReadPlaylist reads the given MIDI files in order. A file that fails to
+// parse doesn't fail the whole playlist: the returned playlist holds every
+// file that could be read, and the error, if non-nil, is a *PlaylistError
+// describing the others.
+func ReadPlaylist(filenames []string) (*Playlist, error) {
+	p := &Playlist{
+		position: -1,
+	}
+
+	var failed []*FileError
+	for _, filename := range filenames {
+		d, err := readMIDIData(filename)
+		if err != nil {
+			failed = append(failed, &FileError{Filename: filename, Err: err})
+			continue
+		}
+		p.Filenames = append(p.Filenames, filename)
+		p.Items = append(p.Items, d)
+	}
+
+	if len(failed) > 0 {
+		return p, &PlaylistError{Errors: failed}
+	}
+	return p, nil
+}
+
+func readMIDIData(filename string) (d *MIDIData, err error) {
+	m, err := ReadMIDI(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Malformed events are reported by panics while building.
+	defer func() {
+		if r := recover(); r != nil {
+			d, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+	return BuildMIDIDataFromMIDIFile(m), nil
+}
+
+// Len returns the number of items in the playlist.
+func (p *Playlist) Len() int {
+	return len(p.Items)
+}
+
+// Current returns the current item, or nil before the first call to Next
+// or after moving past either end.
+func (p *Playlist) Current() *MIDIData {
+	if p.position < 0 || p.position >= len(p.Items) {
+		return nil
+	}
+	return p.Items[p.position]
+}
+
+// Next advances to the next item and returns it, or nil at the end.
+func (p *Playlist) Next() *MIDIData {
+	if p.position < len(p.Items) {
+		p.position++
+	}
+	return p.Current()
+}
+
+// Prev moves back to the previous item and returns it, or nil at the
+// beginning.
+func (p *Playlist) Prev() *MIDIData {
+	if p.position >= 0 {
+		p.position--
+	}
+	return p.Current()
+}
+
+// Duration returns the combined length of all items in seconds.
+func (p *Playlist) Duration() float64 {
+	var total float64
+	for _, d := range p.Items {
+		total += d.Duration()
+	}
+	return total
+}
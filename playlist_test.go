@@ -0,0 +1,56 @@
+package midi
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReadPlaylist(t *testing.T) {
+	p, err := ReadPlaylist([]string{"test.mid", "missing.mid", "test.mid"})
+	var perr *PlaylistError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want *PlaylistError", err)
+	}
+	if len(perr.Errors) != 1 || perr.Errors[0].Filename != "missing.mid" {
+		t.Errorf("unexpected errors: %v", perr.Errors)
+	}
+	if !errors.Is(err.(*PlaylistError).Errors[0], os.ErrNotExist) {
+		t.Errorf("file error should wrap os.ErrNotExist")
+	}
+	if p.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", p.Len())
+	}
+
+	if p.Current() != nil {
+		t.Errorf("Current() before Next should be nil")
+	}
+	if p.Next() != p.Items[0] || p.Next() != p.Items[1] {
+		t.Errorf("Next() returned items out of order")
+	}
+	if p.Next() != nil {
+		t.Errorf("Next() past the end should be nil")
+	}
+	if p.Prev() != p.Items[1] {
+		t.Errorf("Prev() should return the last item")
+	}
+
+	want := 2 * p.Items[0].Duration()
+	if p.Duration() != want {
+		t.Errorf("Duration() = %f, want %f", p.Duration(), want)
+	}
+}
+
+func TestTickToSeconds(t *testing.T) {
+	m, err := ReadMIDI("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+
+	// test.mid sets 428571 microseconds per quarter note at 960 ppq.
+	got := d.TickToSeconds(960)
+	if diff := got - 0.428571; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("TickToSeconds(960) = %f, want 0.428571", got)
+	}
+}
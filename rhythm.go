@@ -0,0 +1,370 @@
+package midi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// onsets returns the sorted, distinct note on ticks of the track.
+func (t *MIDITrack) onsets() []int64 {
+	var ticks []int64
+	for _, e := range t.events {
+		if _, _, on, ok := noteEvent(e.message); ok && on {
+			ticks = append(ticks, e.tick)
+		}
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i] < ticks[j] })
+	return dedupTicks(ticks)
+}
+
+// onsets returns the sorted, distinct note on ticks of all tracks.
+func (d *MIDIData) onsets() []int64 {
+	var ticks []int64
+	for _, t := range d.tracks {
+		ticks = append(ticks, t.onsets()...)
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i] < ticks[j] })
+	return dedupTicks(ticks)
+}
+
+func dedupTicks(ticks []int64) []int64 {
+	out := ticks[:0]
+	for _, tick := range ticks {
+		if n := len(out); n == 0 || out[n-1] != tick {
+			out = append(out, tick)
+		}
+	}
+	return out
+}
+
+// gridError returns the distance from offset to the nearest multiple of
+// step.
+func gridError(offset int64, step float64) float64 {
+	q := float64(offset) / step
+	d := q - float64(int64(q+0.5))
+	if d < 0 {
+		d = -d
+	}
+	return d * step
+}
+
+// Tuplet marks a grid cell whose onsets fit a tuplet subdivision better
+// than a straight one.
+type Tuplet struct {
+	Tick  int64  // start of the grid cell
+	Ratio string // "3:2" for triplets, "6:4" for sextuplets
+}
+
+// DetectTuplets examines the note onsets of all tracks in cells of grid
+// ticks (typically Division, i.e. one beat) and reports the cells where
+// the onsets fit triplet or sextuplet positions clearly better than
+// straight eighths and sixteenths. It is a heuristic meant to stop
+// quantization from destroying triplets.
+func (d *MIDIData) DetectTuplets(grid int64) []Tuplet {
+	if grid < 6 {
+		return nil
+	}
+
+	tolerance := float64(grid) / 24
+	onsets := d.onsets()
+	var tuplets []Tuplet
+	for i := 0; i < len(onsets); {
+		cell := onsets[i] / grid * grid
+		var straight, triplet, sextuplet float64
+		offGrid := 0
+		j := i
+		for ; j < len(onsets) && onsets[j] < cell+grid; j++ {
+			offset := onsets[j] - cell
+			if offset > int64(tolerance) {
+				offGrid++
+			}
+			straight += gridError(offset, float64(grid)/4)
+			triplet += gridError(offset, float64(grid)/3)
+			sextuplet += gridError(offset, float64(grid)/6)
+		}
+		i = j
+
+		if offGrid == 0 || straight <= tolerance {
+			continue
+		}
+		switch {
+		case triplet <= tolerance && triplet < straight/2:
+			tuplets = append(tuplets, Tuplet{Tick: cell, Ratio: "3:2"})
+		case sextuplet <= tolerance && sextuplet < straight/2:
+			tuplets = append(tuplets, Tuplet{Tick: cell, Ratio: "6:4"})
+		}
+	}
+	return tuplets
+}
+
+// SwingRatio measures how late off-beat eighth notes are played. grid is
+// the length of a beat in ticks, normally the Division of the data (one
+// quarter note); beats are assumed to start at multiples of grid from
+// tick 0. Every onset between 40% and 80% of a beat is taken as an
+// off-beat eighth, and the result is its average position within the
+// beat: 0.5 for straight eighths, about 0.67 for triplet swing and 0.75
+// for dotted swing. It returns 0 if the track has no off-beat eighths.
+func (t *MIDITrack) SwingRatio(grid int64) float64 {
+	if grid <= 0 {
+		return 0
+	}
+
+	var sum float64
+	n := 0
+	for _, tick := range t.onsets() {
+		pos := float64(tick%grid) / float64(grid)
+		if pos > 0.4 && pos < 0.8 {
+			sum += pos
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// DensitySample is the note rate of one window of NoteDensity.
+type DensitySample struct {
+	Seconds        float64 // start of the window
+	NotesPerSecond float64
+}
+
+// NoteDensity counts the note ons of all tracks in windows of
+// windowSeconds, starting every hopSeconds from time zero up to the end
+// of the data, and returns the rate of each window. Every note of a chord
+// is counted. It returns nil unless both sizes are positive.
+func (d *MIDIData) NoteDensity(windowSeconds, hopSeconds float64) []DensitySample {
+	if windowSeconds <= 0 || hopSeconds <= 0 {
+		return nil
+	}
+
+	var times []float64
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if _, _, on, ok := noteEvent(e.message); ok && on {
+				times = append(times, d.TickToSeconds(e.tick))
+			}
+		}
+	}
+	sort.Float64s(times)
+
+	var samples []DensitySample
+	duration := d.Duration()
+	lo, hi := 0, 0
+	for i := 0; ; i++ {
+		start := float64(i) * hopSeconds
+		if start >= duration && i > 0 {
+			break
+		}
+		for lo < len(times) && times[lo] < start {
+			lo++
+		}
+		if hi < lo {
+			hi = lo
+		}
+		for hi < len(times) && times[hi] < start+windowSeconds {
+			hi++
+		}
+		samples = append(samples, DensitySample{
+			Seconds:        start,
+			NotesPerSecond: float64(hi-lo) / windowSeconds,
+		})
+	}
+	return samples
+}
+
+// EstimateTimeSignature guesses the meter of the data from its note ons,
+// for files without time signature events. The note on velocities of all
+// tracks are summed on an eighth note grid, and the autocorrelation of
+// that accent pattern at a lag of one 3/4 bar is compared with the lag of
+// one 4/4 bar. A triple meter is reported as 6/8 rather than 3/4 when its
+// accents fall on the fourth eighth of the bar more than on the third and
+// fifth. confidence, from 0 to 1, is how clearly the chosen meter beats
+// the other; 4/4 with confidence 0 is returned when there is nothing to
+// go on. This is a heuristic and doesn't change TimeSignatures.
+func (d *MIDIData) EstimateTimeSignature() (num, denom int, confidence float64) {
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF < 2 {
+		return 4, 4, 0
+	}
+	eighth := int64(d.Division&0x7FFF) / 2
+
+	var accents []float64
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if _, _, on, ok := noteEvent(e.message); !ok || !on {
+				continue
+			}
+			i, _ := gridStep(e.tick, eighth)
+			for int64(len(accents)) <= i {
+				accents = append(accents, 0)
+			}
+			accents[i] += float64(e.message[2])
+		}
+	}
+
+	triple := autocorrelation(accents, 6)
+	duple := autocorrelation(accents, 8)
+	if triple <= 0 && duple <= 0 {
+		return 4, 4, 0
+	}
+	if duple >= triple {
+		return 4, 4, (duple - math.Max(triple, 0)) / duple
+	}
+
+	var middle, sides float64
+	for i, a := range accents {
+		switch i % 6 {
+		case 3:
+			middle += a
+		case 2, 4:
+			sides += a / 2
+		}
+	}
+	confidence = (triple - math.Max(duple, 0)) / triple
+	if middle > sides {
+		return 6, 8, confidence
+	}
+	return 3, 4, confidence
+}
+
+// autocorrelation returns the normalized autocorrelation of x at lag,
+// after removing its mean: 1 for a signal that repeats exactly every lag
+// samples.
+func autocorrelation(x []float64, lag int) float64 {
+	if len(x) <= lag {
+		return 0
+	}
+	var mean float64
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+
+	var num, den float64
+	for i, v := range x {
+		den += (v - mean) * (v - mean)
+		if i+lag < len(x) {
+			num += (v - mean) * (x[i+lag] - mean)
+		}
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den * float64(len(x)) / float64(len(x)-lag)
+}
+
+// IOIHistogram counts the inter-onset intervals of the track: the gaps
+// between consecutive distinct note on ticks, each rounded to the nearest
+// multiple of binTicks, which is the key of its bin. It returns nil if
+// binTicks isn't positive.
+func (t *MIDITrack) IOIHistogram(binTicks int64) map[int64]int {
+	if binTicks <= 0 {
+		return nil
+	}
+	hist := make(map[int64]int)
+	ticks := t.onsets()
+	for i := 1; i < len(ticks); i++ {
+		bin, _ := gridStep(ticks[i]-ticks[i-1], binTicks)
+		hist[bin*binTicks]++
+	}
+	return hist
+}
+
+// IOIHistogramBeats is like IOIHistogram for the given track, with the
+// intervals and bin size measured in quarter note beats using the
+// division, e.g. 0.25 to bin by sixteenth notes.
+func (d *MIDIData) IOIHistogramBeats(track int, binBeats float64) (map[float64]int, error) {
+	if track < 0 || track >= len(d.tracks) {
+		return nil, fmt.Errorf("invalid track %d", track)
+	}
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return nil, errors.New("beats require metrical division")
+	}
+	if binBeats <= 0 {
+		return nil, fmt.Errorf("invalid bin size %g", binBeats)
+	}
+
+	hist := make(map[float64]int)
+	ticks := d.tracks[track].onsets()
+	bin := binBeats * float64(d.Division&0x7FFF)
+	for i := 1; i < len(ticks); i++ {
+		n := math.Floor(float64(ticks[i]-ticks[i-1])/bin + 0.5)
+		hist[n*binBeats]++
+	}
+	return hist, nil
+}
+
+// RhythmRatio is the relation between the pulses of two tracks: A notes
+// of the first track take as long as B notes of the second, e.g. 3:2 for
+// a hemiola.
+type RhythmRatio struct {
+	A, B       int
+	Confidence float64 // 0 to 1
+}
+
+// dominantIOI returns the most common inter-onset interval of t in bins
+// of binTicks, preferring the shorter interval on ties, and the fraction
+// of intervals in that bin.
+func (t *MIDITrack) dominantIOI(binTicks int64) (int64, float64) {
+	var best int64
+	bestCount, total := 0, 0
+	for ioi, n := range t.IOIHistogram(binTicks) {
+		total += n
+		if ioi > 0 && (n > bestCount || n == bestCount && ioi < best) {
+			best, bestCount = ioi, n
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return best, float64(bestCount) / float64(total)
+}
+
+// CrossRhythm compares the dominant inter-onset intervals of two tracks
+// and returns the ratio of small integers, up to 8, that best matches
+// their pulses. The confidence combines how closely the ratio fits with
+// how regular each track is, i.e. the share of its intervals that fall
+// in the dominant bin.
+func (d *MIDIData) CrossRhythm(trackA, trackB int) (RhythmRatio, error) {
+	for _, track := range []int{trackA, trackB} {
+		if track < 0 || track >= len(d.tracks) {
+			return RhythmRatio{}, fmt.Errorf("invalid track %d", track)
+		}
+	}
+	bin := int64(1)
+	if d.Division&0x8000 == 0 && d.Division&0x7FFF >= 24 {
+		bin = int64(d.Division&0x7FFF) / 24
+	}
+
+	periodA, regularA := d.tracks[trackA].dominantIOI(bin)
+	periodB, regularB := d.tracks[trackB].dominantIOI(bin)
+	if periodA == 0 || periodB == 0 {
+		return RhythmRatio{}, errors.New("too few onsets to find a pulse")
+	}
+
+	// A notes of period A span B notes of period B: A/B = periodB/periodA.
+	target := math.Log(float64(periodB) / float64(periodA))
+	best := RhythmRatio{A: 1, B: 1}
+	bestErr := math.Inf(1)
+	for a := 1; a <= 8; a++ {
+		for b := 1; b <= 8; b++ {
+			if gcd(int64(a), int64(b)) != 1 {
+				continue
+			}
+			if e := math.Abs(target - math.Log(float64(a)/float64(b))); e < bestErr {
+				best, bestErr = RhythmRatio{A: a, B: b}, e
+			}
+		}
+	}
+
+	// A ratio that is off by 10% or more doesn't fit at all.
+	fit := 1 - bestErr/math.Log(1.1)
+	if fit < 0 {
+		fit = 0
+	}
+	best.Confidence = fit * (regularA + regularB) / 2
+	return best, nil
+}
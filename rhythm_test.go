@@ -0,0 +1,161 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+// onsetTrack returns a track with short notes starting at the given
+// ticks.
+func onsetTrack(ticks ...int64) *MIDITrack {
+	t := &MIDITrack{}
+	for _, tick := range ticks {
+		t.Append(&MIDIEvent{tick: tick, message: []uint8{0x90, 60, 100}})
+		t.Append(&MIDIEvent{tick: tick + 10, message: []uint8{0x80, 60, 0}})
+	}
+	t.sortEvents()
+	return t
+}
+
+func TestDetectTuplets(t *testing.T) {
+	d := newTestData(480, onsetTrack(
+		0, 240, // straight eighths
+		480, 640, 800, // eighth triplets
+		960, 1080, 1200, 1320, // sixteenths
+		1440, 1521, 1600, 1680, 1760, 1840, // sextuplets, slightly late
+	))
+
+	want := []Tuplet{
+		{Tick: 480, Ratio: "3:2"},
+		{Tick: 1440, Ratio: "6:4"},
+	}
+	if got := d.DetectTuplets(480); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectTuplets(480) = %v, want %v", got, want)
+	}
+}
+
+func TestSwingRatio(t *testing.T) {
+	cases := []struct {
+		track *MIDITrack
+		want  float64
+	}{
+		{onsetTrack(0, 240, 480, 720), 0.5},
+		{onsetTrack(0, 320, 480, 800), 2.0 / 3},
+		{onsetTrack(0, 360, 480, 840, 960, 1080), 0.75},
+		{onsetTrack(0, 480, 960), 0},
+	}
+	for i, c := range cases {
+		if got := c.track.SwingRatio(480); got != c.want {
+			t.Errorf("case %d: SwingRatio = %f, want %f", i, got, c.want)
+		}
+	}
+}
+
+func TestNoteDensity(t *testing.T) {
+	// At 120 bpm and 480 ticks per quarter note, 960 ticks are a second.
+	d := newTestData(480, onsetTrack(0, 240, 480, 720, 960, 2880, 2880))
+	d.At(0).Append(NewMIDIEvent(3840, []uint8{0xFF, 0x2F, 0x00}))
+
+	want := []DensitySample{
+		{Seconds: 0, NotesPerSecond: 2.5},
+		{Seconds: 1, NotesPerSecond: 0.5},
+		{Seconds: 2, NotesPerSecond: 1},
+		{Seconds: 3, NotesPerSecond: 1},
+	}
+	if got := d.NoteDensity(2, 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("NoteDensity(2, 1) = %v, want %v", got, want)
+	}
+	if got := d.NoteDensity(0, 1); got != nil {
+		t.Errorf("NoteDensity(0, 1) = %v, want nil", got)
+	}
+}
+
+// accentTrack returns a track of eighth notes at 480 ticks per quarter
+// note, repeating the given velocities for the given number of bars.
+func accentTrack(bars int, velocities ...uint8) *MIDITrack {
+	t := &MIDITrack{}
+	for bar := 0; bar < bars; bar++ {
+		for i, vel := range velocities {
+			if vel == 0 {
+				continue
+			}
+			tick := int64(bar*len(velocities)+i) * 240
+			t.Append(&MIDIEvent{tick: tick, message: []uint8{0x90, 60, vel}})
+			t.Append(&MIDIEvent{tick: tick + 200, message: []uint8{0x80, 60, 0}})
+		}
+	}
+	return t
+}
+
+func TestEstimateTimeSignature(t *testing.T) {
+	cases := []struct {
+		name       string
+		velocities []uint8
+		num, denom int
+	}{
+		{"waltz", []uint8{120, 0, 60, 0, 60, 0}, 3, 4},
+		{"jig", []uint8{120, 50, 50, 100, 50, 50}, 6, 8},
+		{"march", []uint8{120, 40, 70, 40, 100, 40, 70, 40}, 4, 4},
+	}
+	for _, c := range cases {
+		d := newTestData(480, accentTrack(16, c.velocities...))
+		num, denom, confidence := d.EstimateTimeSignature()
+		if num != c.num || denom != c.denom || confidence <= 0 || confidence > 1 {
+			t.Errorf("%s: EstimateTimeSignature() = %d/%d (%g), want %d/%d",
+				c.name, num, denom, confidence, c.num, c.denom)
+		}
+	}
+
+	num, denom, confidence := newTestData(480).EstimateTimeSignature()
+	if num != 4 || denom != 4 || confidence != 0 {
+		t.Errorf("no notes: EstimateTimeSignature() = %d/%d (%g), want 4/4 (0)",
+			num, denom, confidence)
+	}
+}
+
+func TestIOIHistogram(t *testing.T) {
+	track := onsetTrack(0, 0, 240, 485, 960, 1080, 1200, 1920)
+
+	want := map[int64]int{240: 2, 480: 1, 120: 2, 720: 1}
+	if got := track.IOIHistogram(120); !reflect.DeepEqual(got, want) {
+		t.Errorf("IOIHistogram(120) = %v, want %v", got, want)
+	}
+
+	d := newTestData(480, track)
+	beats, err := d.IOIHistogramBeats(0, 0.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBeats := map[float64]int{0.5: 2, 1: 1, 0.25: 2, 1.5: 1}
+	if !reflect.DeepEqual(beats, wantBeats) {
+		t.Errorf("IOIHistogramBeats(0, 0.25) = %v, want %v", beats, wantBeats)
+	}
+	if _, err := d.IOIHistogramBeats(1, 0.25); err == nil {
+		t.Error("IOIHistogramBeats(1, 0.25) succeeded for a missing track")
+	}
+}
+
+func TestCrossRhythm(t *testing.T) {
+	var triplets, duplets []int64
+	for tick := int64(0); tick < 1920*4; tick += 320 {
+		triplets = append(triplets, tick)
+	}
+	for tick := int64(0); tick < 1920*4; tick += 480 {
+		duplets = append(duplets, tick)
+	}
+	d := newTestData(480, onsetTrack(triplets...), onsetTrack(duplets...))
+
+	r, err := d.CrossRhythm(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.A != 3 || r.B != 2 || r.Confidence < 0.9 {
+		t.Errorf("CrossRhythm(0, 1) = %+v, want 3:2 with high confidence", r)
+	}
+	if r, _ := d.CrossRhythm(1, 0); r.A != 2 || r.B != 3 {
+		t.Errorf("CrossRhythm(1, 0) = %+v, want 2:3", r)
+	}
+	if _, err := d.CrossRhythm(0, 2); err == nil {
+		t.Error("CrossRhythm(0, 2) succeeded for a missing track")
+	}
+}
@@ -0,0 +1,134 @@
+package midi
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+// RepeatedSection is a range of bars whose notes repeat an earlier range
+// of the same length. Bars are numbered from 1 and EndBar is the last bar
+// of the section.
+type RepeatedSection struct {
+	StartBar, EndBar int
+	RepeatOfBar      int // first bar of the earlier occurrence
+}
+
+// RepeatedSections finds runs of at least minBars bars whose notes match
+// an earlier run exactly, apart from velocities, to label song structure
+// such as verses and choruses. Each bar is reduced to a fingerprint of the
+// track, channel, key, position within the bar and length of the notes
+// starting in it. The bars are scanned from the start, taking at each bar
+// the longest match with earlier, non-overlapping bars; a matched section
+// isn't searched again, and sections consisting only of empty bars are
+// not reported. It returns nil for time-code division.
+func (d *MIDIData) RepeatedSections(minBars int) []RepeatedSection {
+	if d.Division&0x8000 > 0 || d.Division&0x7FFF == 0 {
+		return nil
+	}
+	if minBars < 1 {
+		minBars = 1
+	}
+	fingerprints, empty := d.barFingerprints()
+
+	var sections []RepeatedSection
+	for i := 0; i < len(fingerprints); {
+		best, bestLen := -1, 0
+		for j := 0; j < i; j++ {
+			n := 0
+			for j+n < i && i+n < len(fingerprints) &&
+				fingerprints[j+n] == fingerprints[i+n] {
+				n++
+			}
+			if n > bestLen {
+				best, bestLen = j, n
+			}
+		}
+
+		notes := false
+		for k := i; k < i+bestLen; k++ {
+			notes = notes || !empty[k]
+		}
+		if bestLen < minBars || !notes {
+			i++
+			continue
+		}
+		sections = append(sections, RepeatedSection{
+			StartBar:    i + 1,
+			EndBar:      i + bestLen,
+			RepeatOfBar: best + 1,
+		})
+		i += bestLen
+	}
+	return sections
+}
+
+// barFingerprints returns a fingerprint of the notes starting in each bar
+// up to the last tick, and whether the bar has no notes. The division
+// must be metrical.
+func (d *MIDIData) barFingerprints() ([]string, []bool) {
+	last := d.LastTick()
+	lines, err := d.barLines(last)
+	if err != nil {
+		return nil, nil
+	}
+	starts := append([]int64{0}, lines...)
+
+	type barNote struct {
+		track int
+		Note
+	}
+	bars := make([][]barNote, len(starts))
+	for i, t := range d.tracks {
+		for _, n := range t.Notes() {
+			bar := sort.Search(len(starts), func(k int) bool {
+				return starts[k] > n.Start
+			}) - 1
+			n.Start -= starts[bar]
+			n.End -= starts[bar]
+			bars[bar] = append(bars[bar], barNote{track: i, Note: n})
+		}
+	}
+
+	fingerprints := make([]string, len(starts))
+	empty := make([]bool, len(starts))
+	var buf [8]byte
+	for bar, notes := range bars {
+		sort.Slice(notes, func(i, j int) bool {
+			a, b := notes[i], notes[j]
+			if a.Start != b.Start {
+				return a.Start < b.Start
+			}
+			if a.track != b.track {
+				return a.track < b.track
+			}
+			if a.Channel != b.Channel {
+				return a.Channel < b.Channel
+			}
+			if a.Key != b.Key {
+				return a.Key < b.Key
+			}
+			return a.End < b.End
+		})
+
+		end := last
+		if bar+1 < len(starts) {
+			end = starts[bar+1]
+		}
+		h := sha256.New()
+		binary.BigEndian.PutUint64(buf[:], uint64(end-starts[bar]))
+		h.Write(buf[:])
+		for _, n := range notes {
+			binary.BigEndian.PutUint64(buf[:], uint64(n.track))
+			h.Write(buf[:])
+			h.Write([]byte{byte(n.Channel), byte(n.Key)})
+			binary.BigEndian.PutUint64(buf[:], uint64(n.Start))
+			h.Write(buf[:])
+			binary.BigEndian.PutUint64(buf[:], uint64(n.Duration()))
+			h.Write(buf[:])
+		}
+		fingerprints[bar] = string(h.Sum(nil))
+		empty[bar] = len(notes) == 0
+	}
+	return fingerprints, empty
+}
@@ -0,0 +1,40 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRepeatedSections(t *testing.T) {
+	// Bars A B A B C A B, with the repeats played softer.
+	phrases := map[byte][]uint8{'A': {60, 64}, 'B': {62, 65}, 'C': {67, 71}}
+	var events []*MIDIEvent
+	for bar, name := range []byte("ABABCAB") {
+		start := int64(bar) * 1920
+		vel := uint8(100)
+		if bar > 1 {
+			vel = 70
+		}
+		for i, key := range phrases[name] {
+			tick := start + int64(i)*960
+			events = append(events,
+				&MIDIEvent{tick: tick, message: []uint8{0x90, key, vel}},
+				&MIDIEvent{tick: tick + 480, message: []uint8{0x80, key, 0}})
+		}
+	}
+	events = append(events, &MIDIEvent{tick: 7 * 1920, message: []uint8{0xFF, 0x2F, 0x00}})
+	track := newTestTrack(events...)
+	track.sortEvents()
+	d := newTestData(480, track)
+
+	want := []RepeatedSection{
+		{StartBar: 3, EndBar: 4, RepeatOfBar: 1},
+		{StartBar: 6, EndBar: 7, RepeatOfBar: 1},
+	}
+	if got := d.RepeatedSections(2); !reflect.DeepEqual(got, want) {
+		t.Errorf("RepeatedSections(2) = %v, want %v", got, want)
+	}
+	if got := d.RepeatedSections(3); got != nil {
+		t.Errorf("RepeatedSections(3) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,238 @@
+package midi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Song is a simple way to compose MIDI data in musical units: parts hold
+// notes positioned and sized in beats (quarter notes), and ToMIDIData
+// compiles them to ticks.
+type Song struct {
+	Name     string
+	Division int     // ticks per beat
+	BPM      float64 // tempo
+
+	parts []*Part
+}
+
+// Part is a named instrument part of a Song.
+type Part struct {
+	Name       string
+	Instrument string
+	Channel    int
+
+	notes []songNote
+}
+
+type songNote struct {
+	beat, duration  float64
+	pitch, velocity int
+}
+
+// NewSong returns an empty song.
+func NewSong(name string, division int, bpm float64) *Song {
+	return &Song{
+		Name:     name,
+		Division: division,
+		BPM:      bpm,
+	}
+}
+
+// isDrumInstrument reports whether instrument names the percussion kit.
+func isDrumInstrument(instrument string) bool {
+	switch strings.ToLower(instrument) {
+	case "drums", "drum kit", "percussion":
+		return true
+	}
+	return false
+}
+
+// AddPart adds a part playing the given General MIDI instrument, or the
+// percussion kit if instrument is "drums". Each melodic part gets its own
+// channel, skipping the percussion channel.
+func (s *Song) AddPart(name, instrument string) (*Part, error) {
+	if s.Part(name) != nil {
+		return nil, fmt.Errorf("part %q already exists", name)
+	}
+
+	p := &Part{
+		Name:       name,
+		Instrument: instrument,
+		Channel:    PercussionChannel,
+	}
+	if !isDrumInstrument(instrument) {
+		if _, ok := GMProgram(instrument); !ok {
+			return nil, fmt.Errorf("unknown instrument %q", instrument)
+		}
+		used := make(map[int]bool)
+		for _, other := range s.parts {
+			used[other.Channel] = true
+		}
+		p.Channel = -1
+		for ch := 0; ch < 16; ch++ {
+			if ch != PercussionChannel && !used[ch] {
+				p.Channel = ch
+				break
+			}
+		}
+		if p.Channel < 0 {
+			return nil, errors.New("no free channel for part " + name)
+		}
+	}
+
+	s.parts = append(s.parts, p)
+	return p, nil
+}
+
+// Part returns the part with the given name, or nil.
+func (s *Song) Part(name string) *Part {
+	for _, p := range s.parts {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// AddNote adds a note starting at beat (counted from 0) lasting duration
+// beats.
+func (p *Part) AddNote(beat, duration float64, pitch, velocity int) error {
+	if beat < 0 || duration <= 0 {
+		return fmt.Errorf("invalid note position %g+%g", beat, duration)
+	}
+	if pitch < 0 || pitch > 127 || velocity < 1 || velocity > 127 {
+		return fmt.Errorf("invalid pitch %d or velocity %d", pitch, velocity)
+	}
+	p.notes = append(p.notes, songNote{beat, duration, pitch, velocity})
+	return nil
+}
+
+// metaText returns a text-like meta event of the given type.
+func metaText(typ uint8, text string) []uint8 {
+	message := []uint8{0xFF, typ}
+	message = append(message, encodeVarLen(uint64(len(text)))...)
+	return append(message, text...)
+}
+
+// ToMIDIData compiles the song to format 1 data: a first track with the
+// song name and tempo, followed by one track per part.
+func (s *Song) ToMIDIData() *MIDIData {
+	d := &MIDIData{
+		Name:     s.Name,
+		Format:   1,
+		Division: s.Division,
+	}
+	toTick := func(beat float64) int64 {
+		return int64(math.Floor(beat*float64(s.Division) + 0.5))
+	}
+
+	conductor := &MIDITrack{Name: s.Name}
+	conductor.Append(&MIDIEvent{tick: 0, message: metaText(0x03, s.Name)})
+	conductor.Append(&MIDIEvent{tick: 0, message: tempoMessage(s.BPM)})
+	d.Append(conductor)
+
+	var end int64
+	for _, p := range s.parts {
+		t := &MIDITrack{Name: p.Name}
+		t.Append(&MIDIEvent{tick: 0, message: metaText(0x03, p.Name)})
+		if program, ok := GMProgram(p.Instrument); ok {
+			t.Append(&MIDIEvent{tick: 0,
+				message: []uint8{0xC0 | uint8(p.Channel), uint8(program)}})
+		}
+		for _, n := range p.notes {
+			start, stop := toTick(n.beat), toTick(n.beat+n.duration)
+			if stop <= start {
+				stop = start + 1
+			}
+			t.Append(&MIDIEvent{tick: start, message: []uint8{
+				0x90 | uint8(p.Channel), uint8(n.pitch), uint8(n.velocity)}})
+			t.Append(&MIDIEvent{tick: stop, message: []uint8{
+				0x80 | uint8(p.Channel), uint8(n.pitch), 0}})
+		}
+		t.sortEvents()
+		if last := t.lastTick(); last > end {
+			end = last
+		}
+		d.Append(t)
+	}
+
+	for _, t := range d.tracks {
+		t.Append(&MIDIEvent{tick: end, message: []uint8{0xFF, 0x2F, 0x00}})
+	}
+	d.updateMaps()
+	return d
+}
+
+// FromVoices builds format 1 data from independent monophonic lines, such
+// as the voices of a counterpoint exercise: a first track with the tempo,
+// followed by one track per voice. Each voice is played on its own
+// channel, counting from 0 and skipping the percussion channel; the
+// Channel of the notes is ignored. It returns an error if the notes of a
+// voice overlap or are invalid, or if there are more voices than
+// channels.
+func FromVoices(division int, bpm float64, voices [][]Note) (*MIDIData, error) {
+	if division < 1 || division > 0x7FFF {
+		return nil, fmt.Errorf("%w %d", ErrBadDivision, division)
+	}
+	if bpm <= 0 {
+		return nil, fmt.Errorf("invalid tempo %g", bpm)
+	}
+	if len(voices) > 15 {
+		return nil, fmt.Errorf("%d voices, at most 15 channels", len(voices))
+	}
+
+	d := &MIDIData{
+		Format:   1,
+		Division: division,
+	}
+	conductor := &MIDITrack{}
+	conductor.Append(&MIDIEvent{tick: 0, message: tempoMessage(bpm)})
+	d.Append(conductor)
+
+	var end int64
+	for i, voice := range voices {
+		ch := uint8(i)
+		if i >= PercussionChannel {
+			ch++
+		}
+		notes := append([]Note(nil), voice...)
+		sort.SliceStable(notes, func(i, j int) bool {
+			return notes[i].Start < notes[j].Start
+		})
+
+		t := &MIDITrack{}
+		for j, n := range notes {
+			if n.Start < 0 || n.End <= n.Start {
+				return nil, fmt.Errorf("voice %d: invalid note span %d-%d",
+					i, n.Start, n.End)
+			}
+			if n.Key < 0 || n.Key > 127 || n.Velocity < 1 || n.Velocity > 127 {
+				return nil, fmt.Errorf("voice %d: invalid key %d or velocity %d",
+					i, n.Key, n.Velocity)
+			}
+			if j > 0 && n.Start < notes[j-1].End {
+				return nil, fmt.Errorf("voice %d: notes at %d and %d overlap",
+					i, notes[j-1].Start, n.Start)
+			}
+			t.Append(&MIDIEvent{tick: n.Start, message: []uint8{
+				0x90 | ch, uint8(n.Key), uint8(n.Velocity)}})
+			t.Append(&MIDIEvent{tick: n.End, message: []uint8{
+				0x80 | ch, uint8(n.Key), 0}})
+		}
+		t.sortEvents()
+		if last := t.lastTick(); last > end {
+			end = last
+		}
+		d.Append(t)
+	}
+
+	for _, t := range d.tracks {
+		t.Append(&MIDIEvent{tick: end, message: []uint8{0xFF, 0x2F, 0x00}})
+	}
+	d.updateMaps()
+	return d, nil
+}
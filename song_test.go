@@ -0,0 +1,105 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSong(t *testing.T) {
+	s := NewSong("Etude", 480, 120)
+	piano, err := s.AddPart("Piano", "Acoustic Grand Piano")
+	if err != nil {
+		t.Fatal(err)
+	}
+	drums, err := s.AddPart("Drums", "drums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bass, err := s.AddPart("Bass", "electric bass (finger)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if piano.Channel != 0 || drums.Channel != 9 || bass.Channel != 1 {
+		t.Errorf("channels = %d %d %d, want 0 9 1",
+			piano.Channel, drums.Channel, bass.Channel)
+	}
+	if s.Part("Bass") != bass || s.Part("Violin") != nil {
+		t.Errorf("Part lookup failed")
+	}
+	if _, err := s.AddPart("Piano", "Violin"); err == nil {
+		t.Errorf("duplicate part name should fail")
+	}
+	if _, err := s.AddPart("X", "Kazoo"); err == nil {
+		t.Errorf("unknown instrument should fail")
+	}
+
+	piano.AddNote(0, 1, 60, 100)
+	piano.AddNote(1, 0.5, 62, 90)
+	drums.AddNote(0, 0.25, 36, 110)
+	bass.AddNote(2, 2, 36, 80)
+	if err := piano.AddNote(0, 0, 60, 100); err == nil {
+		t.Errorf("zero duration should fail")
+	}
+
+	d := writeAndRead(t, s.ToMIDIData(), WriteOptions{})
+	if d.Len() != 4 {
+		t.Fatalf("Len() = %d, want 4", d.Len())
+	}
+	if got := d.MicrosPerQuarterAt(0); got != 500000 {
+		t.Errorf("tempo = %d, want 500000", got)
+	}
+
+	want := []Note{
+		{Channel: 0, Key: 60, Velocity: 100, Start: 0, End: 480},
+		{Channel: 0, Key: 62, Velocity: 90, Start: 480, End: 720},
+	}
+	if got := d.At(1).Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("piano notes = %v, want %v", got, want)
+	}
+	if pcs := d.At(3).ProgramChanges(); len(pcs) != 1 || pcs[0].Program != 33 {
+		t.Errorf("bass program changes = %v", pcs)
+	}
+	if got := d.LastTick(); got != 1920 {
+		t.Errorf("LastTick() = %d, want 1920", got)
+	}
+}
+
+func TestFromVoices(t *testing.T) {
+	soprano := []Note{
+		{Key: 72, Velocity: 90, Start: 480, End: 960},
+		{Key: 71, Velocity: 90, Start: 0, End: 480},
+	}
+	var voices [][]Note
+	for i := 0; i < 10; i++ {
+		voices = append(voices, soprano)
+	}
+	d, err := FromVoices(480, 90, voices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d = writeAndRead(t, d, WriteOptions{})
+	if d.Format != 1 || d.Len() != 11 {
+		t.Fatalf("Format, Len() = %d, %d, want 1, 11", d.Format, d.Len())
+	}
+	if got := d.MicrosPerQuarterAt(0); got != 666667 {
+		t.Errorf("tempo = %d, want 666667", got)
+	}
+	want := []Note{
+		{Channel: 10, Key: 71, Velocity: 90, Start: 0, End: 480},
+		{Channel: 10, Key: 72, Velocity: 90, Start: 480, End: 960},
+	}
+	if got := d.At(10).Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("last voice = %v, want %v", got, want)
+	}
+	if chs := d.At(1).Channels(); !reflect.DeepEqual(chs, []int{0}) {
+		t.Errorf("first voice channels = %v, want [0]", chs)
+	}
+
+	overlapping := [][]Note{{
+		{Key: 60, Velocity: 90, Start: 0, End: 500},
+		{Key: 62, Velocity: 90, Start: 480, End: 960},
+	}}
+	if _, err := FromVoices(480, 90, overlapping); err == nil {
+		t.Error("overlapping notes should fail")
+	}
+}
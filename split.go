@@ -0,0 +1,96 @@
+package midi
+
+import (
+	"sort"
+)
+
+// mergedEvents returns the events of all tracks in tick order. Events at
+// the same tick keep their track order. End of track events are omitted.
+func (d *MIDIData) mergedEvents() []*MIDIEvent {
+	var events []*MIDIEvent
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if !isEndOfTrack(e.message) {
+				events = append(events, e)
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].tick < events[j].tick
+	})
+	return events
+}
+
+// appendEndOfTrack terminates t at its last event.
+func (t *MIDITrack) appendEndOfTrack() {
+	var tick int64
+	if n := len(t.events); n > 0 {
+		tick = t.events[n-1].tick
+	}
+	t.Append(&MIDIEvent{tick: tick, message: []uint8{0xFF, 0x2F, 0x00}})
+}
+
+// SplitMelodyDrums splits the events of all tracks into two new tracks:
+// drums receives the channel events on PercussionChannel, and melody
+// receives everything else, including meta and system exclusive events.
+// Absolute ticks are kept.
+func (d *MIDIData) SplitMelodyDrums() (melody, drums *MIDITrack) {
+	melody = &MIDITrack{Name: "Melody"}
+	drums = &MIDITrack{Name: "Drums"}
+
+	for _, e := range d.mergedEvents() {
+		if ch, ok := channelOf(e.message); ok && ch == PercussionChannel {
+			drums.Append(e.clone())
+		} else {
+			melody.Append(e.clone())
+		}
+	}
+	melody.appendEndOfTrack()
+	drums.appendEndOfTrack()
+
+	return melody, drums
+}
+
+// SplitAtGaps splits the data where no note sounds for at least
+// minGapSeconds, measured through the tempo map, e.g. to separate the
+// songs of a medley. Each split is made where the silence begins, using
+// Cut, so every segment starts at tick 0 with the tempo, time signature
+// and key signature in effect; segments after the first are then moved
+// earlier so that their first note starts at tick 0. Callers can drop
+// short segments by their Duration. Without such gaps the result is a
+// single copy of the data.
+func (d *MIDIData) SplitAtGaps(minGapSeconds float64) []*MIDIData {
+	var notes []Note
+	for _, t := range d.tracks {
+		notes = append(notes, t.Notes()...)
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Start < notes[j].Start })
+
+	var cuts []int64
+	var end int64
+	for i, n := range notes {
+		if i > 0 && n.Start > end &&
+			d.TickToSeconds(n.Start)-d.TickToSeconds(end) >= minGapSeconds {
+			cuts = append(cuts, end)
+		}
+		if n.End > end {
+			end = n.End
+		}
+	}
+	if len(cuts) == 0 || minGapSeconds <= 0 {
+		return []*MIDIData{d.clone()}
+	}
+
+	last := d.LastTick()
+	segments := make([]*MIDIData, 0, len(cuts)+1)
+	start := int64(0)
+	for _, cut := range append(cuts, last) {
+		s := d.Cut(start, cut)
+		if start > 0 {
+			s.TrimLeadingSilence()
+		}
+		segments = append(segments, s)
+		start = cut
+	}
+	return segments
+}
@@ -0,0 +1,88 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitMelodyDrums(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: tempoMessage(120)},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 240, message: []uint8{0x89, 36, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 480, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+	d.Format = 0
+
+	melody, drums := d.SplitMelodyDrums()
+
+	if melody.Len() != 4 {
+		t.Fatalf("melody has %d events, want 4", melody.Len())
+	}
+	if drums.Len() != 3 {
+		t.Fatalf("drums has %d events, want 3", drums.Len())
+	}
+	if e := drums.At(1); e.Tick() != 240 || e.Message()[0] != 0x89 {
+		t.Errorf("unexpected drum event %d % X", e.Tick(), e.Message())
+	}
+	if e := melody.At(2); e.Tick() != 480 || e.Message()[0] != 0x80 {
+		t.Errorf("unexpected melody event %d % X", e.Tick(), e.Message())
+	}
+	for _, track := range []*MIDITrack{melody, drums} {
+		if e := track.At(track.Len() - 1); !isEndOfTrack(e.Message()) {
+			t.Errorf("%s: missing end of track", track.Name)
+		}
+	}
+}
+
+func TestSplitAtGaps(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(100)},
+			&MIDIEvent{tick: 4080, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 480, message: []uint8{0x90, 62, 100}},
+			&MIDIEvent{tick: 960, message: []uint8{0x80, 62, 0}},
+			&MIDIEvent{tick: 2400, message: []uint8{0xC0, 40}},
+			&MIDIEvent{tick: 2880, message: []uint8{0x90, 64, 100}},
+			&MIDIEvent{tick: 3360, message: []uint8{0x80, 64, 0}},
+			&MIDIEvent{tick: 3600, message: []uint8{0x90, 65, 100}},
+			&MIDIEvent{tick: 4080, message: []uint8{0x80, 65, 0}},
+			&MIDIEvent{tick: 4080, message: []uint8{0xFF, 0x2F, 0x00}},
+		))
+
+	segments := d.SplitAtGaps(1)
+	if len(segments) != 2 {
+		t.Fatalf("got %d segments, want 2", len(segments))
+	}
+	want := []Note{
+		{Key: 60, Velocity: 100, Start: 0, End: 480},
+		{Key: 62, Velocity: 100, Start: 480, End: 960},
+	}
+	if got := segments[0].At(1).Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("first segment = %v, want %v", got, want)
+	}
+	want = []Note{
+		{Key: 64, Velocity: 100, Start: 0, End: 480},
+		{Key: 65, Velocity: 100, Start: 720, End: 1200},
+	}
+	second := segments[1]
+	if got := second.At(1).Notes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("second segment = %v, want %v", got, want)
+	}
+	if got := second.At(1).ProgramChanges(); len(got) != 1 || got[0].Tick != 0 {
+		t.Errorf("ProgramChanges() = %v, want one at tick 0", got)
+	}
+	if got := second.MicrosPerQuarterAt(0); got != 600000 {
+		t.Errorf("tempo = %d, want 600000", got)
+	}
+
+	if got := d.SplitAtGaps(5); len(got) != 1 || !got[0].At(1).Equal(d.At(1)) {
+		t.Errorf("SplitAtGaps(5) should return a copy of the data")
+	}
+}
@@ -0,0 +1,92 @@
+// Package synth plays MIDI data in real time through a Synth, such as an
+// audio engine or a hardware port. It only uses the exported API of the
+// midi package, so programs that don't play MIDI needn't import it.
+package synth
+
+import (
+	"context"
+	"time"
+
+	"github.com/r9y9/midi"
+)
+
+// Synth receives the channel messages of MIDI data as they become due
+// during Render. Implementations typically drive an audio engine or a
+// hardware port; the package doesn't provide one.
+type Synth interface {
+	NoteOn(channel, key, velocity int)
+	NoteOff(channel, key int)
+	ControlChange(channel, controller, value int)
+	ProgramChange(channel, program int)
+	PitchBend(channel, value int) // -8192 to 8191
+}
+
+// Render plays d in real time, calling s for each channel message when
+// its time, resolved through the tempo map, has come. Note ons with zero
+// velocity are sent as note offs, channel mode messages as control
+// changes, and other messages are skipped. Render blocks until the end of
+// the data or until ctx is done; in the latter case every sounding note
+// is released and ctx.Err() is returned.
+func Render(ctx context.Context, d *midi.MIDIData, s Synth) error {
+	sounding := make(map[[2]int]int)
+	start := time.Now()
+	var err error
+	d.Walk(func(track int, e *midi.MIDIEvent) bool {
+		seconds := d.TickToSeconds(e.Tick())
+		at := start.Add(time.Duration(seconds * float64(time.Second)))
+		if wait := time.Until(at); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if err = ctx.Err(); err != nil {
+			return false
+		}
+
+		msg, perr := midi.ParseMessage(e.Message())
+		if perr != nil {
+			return true
+		}
+		switch m := msg.(type) {
+		case midi.NoteOn:
+			if m.Velocity == 0 {
+				noteOff(s, sounding, m.Channel, m.Key)
+				break
+			}
+			sounding[[2]int{m.Channel, m.Key}]++
+			s.NoteOn(m.Channel, m.Key, m.Velocity)
+		case midi.NoteOff:
+			noteOff(s, sounding, m.Channel, m.Key)
+		case midi.ControlChange:
+			s.ControlChange(m.Channel, m.Controller, m.Value)
+		case midi.ChannelMode:
+			s.ControlChange(m.Channel, int(m.Mode), m.Value)
+		case midi.ProgramChange:
+			s.ProgramChange(m.Channel, m.Program)
+		case midi.PitchBend:
+			s.PitchBend(m.Channel, m.Value)
+		}
+		return true
+	})
+
+	if err != nil {
+		for k, n := range sounding {
+			for ; n > 0; n-- {
+				s.NoteOff(k[0], k[1])
+			}
+		}
+	}
+	return err
+}
+
+// noteOff releases a note and counts it as no longer sounding.
+func noteOff(s Synth, sounding map[[2]int]int, channel, key int) {
+	k := [2]int{channel, key}
+	if sounding[k] > 0 {
+		sounding[k]--
+	}
+	s.NoteOff(channel, key)
+}
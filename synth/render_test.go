@@ -0,0 +1,94 @@
+package synth
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/r9y9/midi"
+)
+
+// newTestData returns data with a single track of events at 480 ticks per
+// quarter note.
+func newTestData(events ...*midi.MIDIEvent) *midi.MIDIData {
+	track := &midi.MIDITrack{}
+	for _, e := range events {
+		track.Append(e)
+	}
+	d := &midi.MIDIData{Division: 480}
+	d.Append(track)
+	return d
+}
+
+// recordingSynth records the calls made to it.
+type recordingSynth struct {
+	calls []string
+}
+
+func (s *recordingSynth) NoteOn(channel, key, velocity int) {
+	s.calls = append(s.calls, fmt.Sprintf("on %d %d %d", channel, key, velocity))
+}
+
+func (s *recordingSynth) NoteOff(channel, key int) {
+	s.calls = append(s.calls, fmt.Sprintf("off %d %d", channel, key))
+}
+
+func (s *recordingSynth) ControlChange(channel, controller, value int) {
+	s.calls = append(s.calls, fmt.Sprintf("cc %d %d %d", channel, controller, value))
+}
+
+func (s *recordingSynth) ProgramChange(channel, program int) {
+	s.calls = append(s.calls, fmt.Sprintf("pc %d %d", channel, program))
+}
+
+func (s *recordingSynth) PitchBend(channel, value int) {
+	s.calls = append(s.calls, fmt.Sprintf("bend %d %d", channel, value))
+}
+
+func TestRender(t *testing.T) {
+	// 960 ticks per second, so the data lasts about 20 ms.
+	d := newTestData(
+		midi.NewMIDIEvent(0, []uint8{0xC1, 40}),
+		midi.NewMIDIEvent(0, []uint8{0xB1, 7, 100}),
+		midi.NewMIDIEvent(0, []uint8{0x91, 60, 90}),
+		midi.NewMIDIEvent(10, []uint8{0xE1, 0x00, 0x40}),
+		midi.NewMIDIEvent(20, []uint8{0x91, 60, 0}),
+	)
+
+	var synth recordingSynth
+	if err := Render(context.Background(), d, &synth); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"pc 1 40", "cc 1 7 100", "on 1 60 90", "bend 1 0", "off 1 60"}
+	if !reflect.DeepEqual(synth.calls, want) {
+		t.Errorf("calls = %v, want %v", synth.calls, want)
+	}
+}
+
+// cancelingSynth cancels rendering at the first note on.
+type cancelingSynth struct {
+	recordingSynth
+	cancel context.CancelFunc
+}
+
+func (s *cancelingSynth) NoteOn(channel, key, velocity int) {
+	s.recordingSynth.NoteOn(channel, key, velocity)
+	s.cancel()
+}
+
+func TestRenderCancel(t *testing.T) {
+	d := newTestData(
+		midi.NewMIDIEvent(0, []uint8{0x90, 60, 90}),
+		midi.NewMIDIEvent(96000, []uint8{0x80, 60, 0}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	synth := cancelingSynth{cancel: cancel}
+	if err := Render(ctx, d, &synth); err != context.Canceled {
+		t.Fatalf("Render() error = %v, want context.Canceled", err)
+	}
+	if n := len(synth.calls); n == 0 || synth.calls[n-1] != "off 0 60" {
+		t.Errorf("calls = %v, want the note released", synth.calls)
+	}
+}
@@ -0,0 +1,80 @@
+package midi
+
+import (
+	"bytes"
+)
+
+// ResetType reports which standard reset the message is: "GM" for
+// General MIDI System On, "GM2" for General MIDI 2 System On, "GS" for
+// the Roland GS reset and "XG" for the Yamaha XG System On. It returns
+// "" for other messages. Device IDs are ignored.
+func (s SysEx) ResetType() string {
+	d := s.Data
+	switch {
+	case len(d) == 5 && d[0] == 0x7E && d[2] == 0x09 && d[4] == 0xF7:
+		switch d[3] {
+		case 0x01:
+			return "GM"
+		case 0x03:
+			return "GM2"
+		}
+	case len(d) == 10 && d[0] == 0x41 && d[2] == 0x42 &&
+		bytes.Equal(d[3:], []byte{0x12, 0x40, 0x00, 0x7F, 0x00, 0x41, 0xF7}):
+		return "GS"
+	case len(d) == 8 && d[0] == 0x43 && d[1]&0xF0 == 0x10 &&
+		bytes.Equal(d[2:], []byte{0x4C, 0x00, 0x00, 0x7E, 0x00, 0xF7}):
+		return "XG"
+	}
+	return ""
+}
+
+// isSetupSysEx reports whether e belongs to the device setup block: a
+// well-formed system exclusive event at tick 0.
+func isSetupSysEx(e *MIDIEvent) bool {
+	if e.tick != 0 || len(e.message) == 0 ||
+		e.message[0] != 0xF0 && e.message[0] != 0xF7 {
+		return false
+	}
+	_, err := lengthPrefixed(e.message[1:])
+	return err == nil
+}
+
+// SetupSysEx returns the system exclusive events at tick 0 of the first
+// track, the block that resets and configures the synthesizer before the
+// music starts. ResetType identifies the GM, GS and XG resets among them.
+func (d *MIDIData) SetupSysEx() []SysEx {
+	if len(d.tracks) == 0 {
+		return nil
+	}
+	var setup []SysEx
+	for _, e := range d.tracks[0].events {
+		if !isSetupSysEx(e) {
+			continue
+		}
+		if msg, err := ParseMessage(e.message); err == nil {
+			setup = append(setup, msg.(SysEx))
+		}
+	}
+	return setup
+}
+
+// StripSetup removes the events returned by SetupSysEx, e.g. to play a
+// GS or XG file on a plain GM synthesizer, and returns how many were
+// removed.
+func (d *MIDIData) StripSetup() int {
+	if len(d.tracks) == 0 {
+		return 0
+	}
+	t := d.tracks[0]
+	events := t.events[:0]
+	removed := 0
+	for _, e := range t.events {
+		if isSetupSysEx(e) {
+			removed++
+			continue
+		}
+		events = append(events, e)
+	}
+	t.events = events
+	return removed
+}
@@ -0,0 +1,45 @@
+package midi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSetupSysEx(t *testing.T) {
+	track := []byte{
+		0x00, 0xF0, 0x05, 0x7E, 0x7F, 0x09, 0x01, 0xF7,
+		0x00, 0xF0, 0x0A, 0x41, 0x10, 0x42, 0x12, 0x40, 0x00, 0x7F, 0x00, 0x41, 0xF7,
+		0x00, 0x90, 0x3C, 0x64,
+		0x60, 0xF0, 0x08, 0x43, 0x10, 0x4C, 0x00, 0x00, 0x7E, 0x00, 0xF7,
+		0x00, 0x80, 0x3C, 0x00,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	m, err := Read(bytes.NewReader(buildSMF(0, 480, track)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+	if d.At(0).Len() != 6 {
+		t.Fatalf("Len() = %d, want 6", d.At(0).Len())
+	}
+
+	setup := d.SetupSysEx()
+	var resets []string
+	for _, s := range setup {
+		resets = append(resets, s.ResetType())
+	}
+	if !reflect.DeepEqual(resets, []string{"GM", "GS"}) {
+		t.Errorf("SetupSysEx() resets = %q, want GM and GS", resets)
+	}
+	if got := d.At(0).At(3).Message(); (SysEx{Status: 0xF0, Data: got[2:]}).ResetType() != "XG" {
+		t.Errorf("late sysex % X isn't an XG reset", got)
+	}
+
+	if n := d.StripSetup(); n != 2 {
+		t.Errorf("StripSetup() = %d, want 2", n)
+	}
+	if d.At(0).Len() != 4 || len(d.SetupSysEx()) != 0 {
+		t.Errorf("Len() = %d after StripSetup, want 4", d.At(0).Len())
+	}
+}
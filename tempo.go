@@ -0,0 +1,369 @@
+package midi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// defaultTickSeconds returns the tick duration implied by a division
+// before any tempo event is seen: 120 beats per minute for metrical time,
+// or the frame rate times the ticks per frame for time-code based
+// division.
+func defaultTickSeconds(division int) float64 {
+	if division&0x8000 > 0 {
+		fps, _ := smpteFormat(division)
+		ticksPerFrame := float64(division & 0xFF)
+		if fps <= 0 || ticksPerFrame == 0 {
+			return 0
+		}
+		return 1.0 / (fps * ticksPerFrame)
+	}
+
+	ppq := division & 0x7FFF
+	if ppq == 0 {
+		return 0
+	}
+	return 0.5 / float64(ppq)
+}
+
+// smpteFormat returns the frame rate of a time-code based division. The
+// upper byte holds the negated SMPTE format: -24, -25, -29 (30 fps drop
+// frame, running at 29.97 frames per second) or -30 (30 fps non-drop).
+// For metrical division it returns 0 and false.
+func smpteFormat(division int) (float64, bool) {
+	if division&0x8000 == 0 {
+		return 0, false
+	}
+	format := -int(int8(division >> 8))
+	if format == 29 {
+		return 30000.0 / 1001.0, true
+	}
+	return float64(format), false
+}
+
+// SMPTEDivision returns the time-code based division for fps frames per
+// second, one of 24, 25, 29 (30 fps drop frame) and 30, and
+// ticksPerFrame ticks per frame, as read into MIDIFile.Division. Use it
+// as the Division of MIDIData to write frame-locked files.
+func SMPTEDivision(fps, ticksPerFrame int) (int, error) {
+	switch fps {
+	case 24, 25, 29, 30:
+	default:
+		return 0, fmt.Errorf("%w: invalid frame rate %d", ErrBadDivision, fps)
+	}
+	if ticksPerFrame < 1 || ticksPerFrame > 0xFF {
+		return 0, fmt.Errorf("%w: invalid ticks per frame %d",
+			ErrBadDivision, ticksPerFrame)
+	}
+	return int(int16(uint16(uint8(-int8(fps)))<<8 | uint16(ticksPerFrame))), nil
+}
+
+// validDivision reports whether division is a metrical division or a
+// time-code based division with a standard frame rate.
+func validDivision(division int) bool {
+	if division&0x8000 == 0 {
+		return division&0x7FFF != 0
+	}
+	fps, _ := smpteFormat(division)
+	switch fps {
+	case 24, 25, 30000.0 / 1001.0, 30:
+		return division&0xFF != 0
+	}
+	return false
+}
+
+// isTempoEvent reports whether message is a set tempo meta event.
+func isTempoEvent(message []uint8) bool {
+	return len(message) == 6 && message[0] == 0xFF &&
+		message[1] == 0x51 && message[2] == 0x03
+}
+
+// updateTempoMap rebuilds the tempo map from the set tempo meta events
+// found in all tracks. It must be called whenever tempo events are added,
+// removed or moved.
+func (d *MIDIData) updateTempoMap() {
+	d.tempoEvents = []TempoChange{{
+		Count:            0,
+		TickSeconds:      defaultTickSeconds(d.Division),
+		MicrosPerQuarter: defaultMicrosPerQuarter,
+	}}
+
+	// Tempo events don't affect time-code based timing.
+	if d.Division&0x8000 > 0 {
+		return
+	}
+
+	var changes []TempoChange
+	ppq := float64(d.Division & 0x7FFF)
+	for _, t := range d.tracks {
+		for _, e := range t.events {
+			if !isTempoEvent(e.message) {
+				continue
+			}
+			value := tempoValue(e.message)
+			changes = append(changes, TempoChange{
+				Count:            uint64(e.tick),
+				TickSeconds:      0.000001 * float64(value) / ppq,
+				MicrosPerQuarter: value,
+			})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Count < changes[j].Count
+	})
+
+	for _, c := range changes {
+		tail := len(d.tempoEvents) - 1
+		if c.Count > d.tempoEvents[tail].Count {
+			d.tempoEvents = append(d.tempoEvents, c)
+		} else {
+			d.tempoEvents[tail] = c
+		}
+	}
+}
+
+// TempoChanges returns the tempo map, starting with the tempo in effect
+// at tick 0.
+func (d *MIDIData) TempoChanges() []TempoChange {
+	if d.tempoEvents == nil {
+		d.updateTempoMap()
+	}
+	return d.tempoEvents
+}
+
+// MicrosPerQuarterAt returns the tempo in effect at tick as microseconds
+// per quarter note, exactly as stored in the set tempo event.
+func (d *MIDIData) MicrosPerQuarterAt(tick int64) uint32 {
+	tempo := d.TempoChanges()
+	value := tempo[0].MicrosPerQuarter
+	for _, c := range tempo {
+		if int64(c.Count) > tick {
+			break
+		}
+		value = c.MicrosPerQuarter
+	}
+	return value
+}
+
+// TickToSeconds converts an absolute tick to seconds using the tempo map.
+func (d *MIDIData) TickToSeconds(tick int64) float64 {
+	return tickToSeconds(d.TempoChanges(), tick)
+}
+
+// tickToSeconds converts an absolute tick to seconds using tempo.
+func tickToSeconds(tempo []TempoChange, tick int64) float64 {
+	var seconds float64
+	for i, c := range tempo {
+		if i+1 < len(tempo) && int64(tempo[i+1].Count) < tick {
+			seconds += float64(tempo[i+1].Count-c.Count) * c.TickSeconds
+			continue
+		}
+		seconds += float64(tick-int64(c.Count)) * c.TickSeconds
+		break
+	}
+	return seconds
+}
+
+// LastTick returns the largest tick of any event in any track.
+func (d *MIDIData) LastTick() int64 {
+	var last int64
+	for _, t := range d.tracks {
+		if n := t.Len(); n > 0 && t.At(n-1).Tick() > last {
+			last = t.At(n - 1).Tick()
+		}
+	}
+	return last
+}
+
+// Duration returns the length of the data in seconds.
+func (d *MIDIData) Duration() float64 {
+	return d.TickToSeconds(d.LastTick())
+}
+
+// tempoMessage returns a set tempo meta event for bpm beats per minute.
+func tempoMessage(bpm float64) []uint8 {
+	micros := math.Floor(60000000/bpm + 0.5)
+	if micros < 1 {
+		micros = 1
+	}
+	if micros > 0xFFFFFF {
+		micros = 0xFFFFFF
+	}
+	value := uint32(micros)
+	return []uint8{0xFF, 0x51, 0x03,
+		uint8(value >> 16), uint8(value >> 8), uint8(value)}
+}
+
+// removeTempoEvents deletes the set tempo events in [start, end].
+func (d *MIDIData) removeTempoEvents(start, end int64) {
+	for _, t := range d.tracks {
+		events := t.events[:0]
+		for _, e := range t.events {
+			if isTempoEvent(e.message) && e.tick >= start && e.tick <= end {
+				continue
+			}
+			events = append(events, e)
+		}
+		t.events = events
+	}
+}
+
+// SetTempo sets the tempo at tick to bpm beats per minute, replacing any
+// tempo event already at that tick. The event is placed on the first
+// track, which is created if necessary.
+func (d *MIDIData) SetTempo(tick int64, bpm float64) error {
+	if d.Division&0x8000 > 0 {
+		return errors.New("tempo has no effect with time-code division")
+	}
+	if tick < 0 || bpm <= 0 {
+		return fmt.Errorf("invalid tempo %f at tick %d", bpm, tick)
+	}
+
+	if len(d.tracks) == 0 {
+		d.Append(&MIDITrack{})
+	}
+	d.removeTempoEvents(tick, tick)
+	d.tracks[0].insert(&MIDIEvent{tick: tick, message: tempoMessage(bpm)})
+	d.updateTempoMap()
+
+	return nil
+}
+
+// RampShape selects how AddTempoRamp interpolates between tempos.
+type RampShape int
+
+const (
+	// LinearRamp changes the tempo by a constant number of BPM per step.
+	LinearRamp RampShape = iota
+	// ExponentialRamp changes the tempo by a constant ratio per step.
+	ExponentialRamp
+)
+
+// AddTempoRamp replaces the tempo events in [startTick, endTick] with
+// steps tempo changes going from startBPM to endBPM, followed by endBPM at
+// endTick. This scripts accelerandos and ritardandos.
+func (d *MIDIData) AddTempoRamp(startTick, endTick int64,
+	startBPM, endBPM float64, steps int, shape RampShape) error {
+	if d.Division&0x8000 > 0 {
+		return errors.New("tempo has no effect with time-code division")
+	}
+	if steps < 1 {
+		return fmt.Errorf("invalid number of steps %d", steps)
+	}
+	if startTick < 0 || endTick <= startTick {
+		return fmt.Errorf("invalid tick range %d-%d", startTick, endTick)
+	}
+	if startBPM <= 0 || endBPM <= 0 {
+		return errors.New("tempo must be positive")
+	}
+	if int64(steps) > endTick-startTick {
+		return fmt.Errorf("%d steps don't fit in %d ticks",
+			steps, endTick-startTick)
+	}
+
+	if len(d.tracks) == 0 {
+		d.Append(&MIDITrack{})
+	}
+	d.removeTempoEvents(startTick, endTick)
+
+	for i := 0; i <= steps; i++ {
+		x := float64(i) / float64(steps)
+		var bpm float64
+		switch shape {
+		case ExponentialRamp:
+			bpm = startBPM * math.Pow(endBPM/startBPM, x)
+		default:
+			bpm = startBPM + (endBPM-startBPM)*x
+		}
+		tick := startTick + (endTick-startTick)*int64(i)/int64(steps)
+		d.tracks[0].insert(&MIDIEvent{tick: tick, message: tempoMessage(bpm)})
+	}
+	d.updateTempoMap()
+
+	return nil
+}
+
+// EventMicros returns the time of every event in microseconds, in the
+// order of the events of the single track of ToFormat0, without its end
+// of track event: all events except end of track in tick order, events at
+// the same tick in track order. Times are computed from the tempo map in
+// integer arithmetic: the exact time is a fraction whose numerator sums
+// ticks times microseconds per quarter note over the tempo segments, and
+// only the final division is rounded, half up, to the nearest
+// microsecond. Rounding errors therefore never accumulate, however long
+// the data. Time-code division uses the frame rate, with 29.97 frames per
+// second for 30 fps drop frame.
+func (d *MIDIData) EventMicros() []int64 {
+	events := d.mergedEvents()
+	micros := make([]int64, len(events))
+	round := func(num, den int64) int64 { return (2*num + den) / (2 * den) }
+
+	if d.Division&0x8000 > 0 {
+		fps, _ := smpteFormat(d.Division)
+		num, den := int64(fps), int64(1)
+		if fps != float64(num) {
+			num, den = 30000, 1001
+		}
+		ticksPerFrame := int64(d.Division & 0xFF)
+		if num <= 0 || ticksPerFrame == 0 {
+			return micros
+		}
+		for i, e := range events {
+			micros[i] = round(e.tick*1000000*den, num*ticksPerFrame)
+		}
+		return micros
+	}
+
+	ppq := int64(d.Division & 0x7FFF)
+	if ppq == 0 {
+		return micros
+	}
+	tempo := d.TempoChanges()
+	var sum, last int64 // sum is ticks times microseconds per quarter
+	next := 1
+	for i, e := range events {
+		for next < len(tempo) && int64(tempo[next].Count) <= e.tick {
+			sum += (int64(tempo[next].Count) - last) *
+				int64(tempo[next-1].MicrosPerQuarter)
+			last = int64(tempo[next].Count)
+			next++
+		}
+		micros[i] = round(sum+(e.tick-last)*int64(tempo[next-1].MicrosPerQuarter), ppq)
+	}
+	return micros
+}
+
+// FrameEvents holds the messages that fall in one frame of an audio
+// feature grid.
+type FrameEvents struct {
+	Frame    int
+	Messages [][]byte // copies of the event messages, in event order
+}
+
+// ToFrameEvents buckets the events of the data into frames of hopSize
+// samples at sampleRate, to align them with audio features computed with
+// that hop. The time of each event is taken from EventMicros and rounded,
+// half up, to the nearest sample; the frame is that sample divided by
+// hopSize, rounded down, so frame n covers samples n*hopSize up to
+// (n+1)*hopSize. Only frames with events are returned, in frame order,
+// and several events in one frame keep the order of EventMicros. It
+// returns nil if sampleRate or hopSize isn't positive.
+func (d *MIDIData) ToFrameEvents(sampleRate, hopSize int) []FrameEvents {
+	if sampleRate <= 0 || hopSize <= 0 {
+		return nil
+	}
+	var frames []FrameEvents
+	micros := d.EventMicros()
+	for i, e := range d.mergedEvents() {
+		sample := (2*micros[i]*int64(sampleRate) + 1000000) / 2000000
+		frame := int(sample / int64(hopSize))
+		if n := len(frames); n == 0 || frames[n-1].Frame != frame {
+			frames = append(frames, FrameEvents{Frame: frame})
+		}
+		f := &frames[len(frames)-1]
+		f.Messages = append(f.Messages, append([]byte(nil), e.message...))
+	}
+	return frames
+}
@@ -0,0 +1,158 @@
+package midi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func bpmOf(c TempoChange, division int) float64 {
+	return 60 / (c.TickSeconds * float64(division))
+}
+
+func TestAddTempoRamp(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: tempoMessage(120)},
+		&MIDIEvent{tick: 960, message: tempoMessage(100)},
+		&MIDIEvent{tick: 4800, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+
+	if err := d.AddTempoRamp(960, 1920, 120, 60, 4, LinearRamp); err != nil {
+		t.Fatal(err)
+	}
+
+	tempo := d.TempoChanges()
+	wantTicks := []uint64{0, 960, 1200, 1440, 1680, 1920}
+	wantBPM := []float64{120, 120, 105, 90, 75, 60}
+	if len(tempo) != len(wantTicks) {
+		t.Fatalf("got %d tempo changes, want %d", len(tempo), len(wantTicks))
+	}
+	for i, c := range tempo {
+		if c.Count != wantTicks[i] {
+			t.Errorf("change %d at tick %d, want %d", i, c.Count, wantTicks[i])
+		}
+		if bpm := bpmOf(c, 480); math.Abs(bpm-wantBPM[i]) > 0.01 {
+			t.Errorf("change %d = %f bpm, want %f", i, bpm, wantBPM[i])
+		}
+	}
+
+	track := d.At(0)
+	if e := track.At(track.Len() - 1); !isEndOfTrack(e.Message()) {
+		t.Errorf("end of track must stay last, got % X", e.Message())
+	}
+}
+
+func TestAddTempoRampExponential(t *testing.T) {
+	d := newTestData(480, newTestTrack())
+	if err := d.AddTempoRamp(0, 960, 60, 240, 2, ExponentialRamp); err != nil {
+		t.Fatal(err)
+	}
+	tempo := d.TempoChanges()
+	for i, want := range []float64{60, 120, 240} {
+		if bpm := bpmOf(tempo[i], 480); math.Abs(bpm-want) > 0.01 {
+			t.Errorf("change %d = %f bpm, want %f", i, bpm, want)
+		}
+	}
+}
+
+func TestAddTempoRampInvalid(t *testing.T) {
+	d := newTestData(480, newTestTrack())
+	if err := d.AddTempoRamp(0, 960, 60, 120, 0, LinearRamp); err == nil {
+		t.Errorf("zero steps should fail")
+	}
+	if err := d.AddTempoRamp(960, 960, 60, 120, 2, LinearRamp); err == nil {
+		t.Errorf("empty range should fail")
+	}
+	if err := d.AddTempoRamp(0, 960, 0, 120, 2, LinearRamp); err == nil {
+		t.Errorf("zero bpm should fail")
+	}
+}
+
+func TestMicrosPerQuarterAt(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x51, 0x03, 0x06, 0x8A, 0x1B}},
+		&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x51, 0x03, 0x0F, 0x42, 0x40}},
+	))
+
+	cases := []struct {
+		tick int64
+		want uint32
+	}{
+		{0, 428571},
+		{959, 428571},
+		{960, 1000000},
+		{100000, 1000000},
+	}
+	for _, c := range cases {
+		if got := d.MicrosPerQuarterAt(c.tick); got != c.want {
+			t.Errorf("MicrosPerQuarterAt(%d) = %d, want %d", c.tick, got, c.want)
+		}
+	}
+
+	empty := newTestData(480)
+	if got := empty.MicrosPerQuarterAt(0); got != 500000 {
+		t.Errorf("default tempo = %d, want 500000", got)
+	}
+}
+
+func TestMIDIFileTempoMap(t *testing.T) {
+	m, err := ReadMIDI("test.mid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.tempoEvents[len(m.tempoEvents)-1].MicrosPerQuarter; got != 428571 {
+		t.Errorf("MicrosPerQuarter = %d, want 428571", got)
+	}
+}
+
+func TestEventMicros(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 960, message: tempoMessage(150)},
+			&MIDIEvent{tick: 961, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 1, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 961, message: []uint8{0xB0, 7, 100}},
+		))
+	want := []int64{0, 0, 1042, 1000000, 1000833}
+	if got := d.EventMicros(); !reflect.DeepEqual(got, want) {
+		t.Errorf("EventMicros() = %v, want %v", got, want)
+	}
+
+	division, _ := SMPTEDivision(29, 1)
+	d = newTestData(division, newTestTrack(
+		&MIDIEvent{tick: 1, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 30000, message: []uint8{0x80, 60, 0}},
+	))
+	want = []int64{33367, 1001000000}
+	if got := d.EventMicros(); !reflect.DeepEqual(got, want) {
+		t.Errorf("EventMicros() with drop frame = %v, want %v", got, want)
+	}
+}
+
+func TestToFrameEvents(t *testing.T) {
+	d := newTestData(480, newTestTrack(
+		&MIDIEvent{tick: 0, message: tempoMessage(120)},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 1, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 47, message: []uint8{0x90, 62, 100}},
+		&MIDIEvent{tick: 48, message: []uint8{0x80, 62, 0}},
+		&MIDIEvent{tick: 48, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 48, message: []uint8{0xFF, 0x2F, 0x00}},
+	))
+
+	want := []FrameEvents{
+		{Frame: 0, Messages: [][]byte{tempoMessage(120), {0x90, 60, 100}, {0x80, 60, 0}}},
+		{Frame: 4, Messages: [][]byte{{0x90, 62, 100}}},
+		{Frame: 5, Messages: [][]byte{{0x80, 62, 0}, {0x90, 64, 100}}},
+	}
+	if got := d.ToFrameEvents(16000, 160); !reflect.DeepEqual(got, want) {
+		t.Errorf("ToFrameEvents(16000, 160) = %v, want %v", got, want)
+	}
+	if got := d.ToFrameEvents(16000, 0); got != nil {
+		t.Errorf("ToFrameEvents(16000, 0) = %v, want nil", got)
+	}
+}
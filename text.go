@@ -0,0 +1,124 @@
+package midi
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset decodes the bytes of a text meta event. Decoders for other
+// encodings, such as Shift-JIS found in Japanese karaoke files, can be
+// adapted from golang.org/x/text:
+//
+//	sjis := func(b []byte) string {
+//		s, _ := japanese.ShiftJIS.NewDecoder().Bytes(b)
+//		return string(s)
+//	}
+//	m, err := midi.ReadWithOptions(r, midi.ReadOptions{Charset: sjis})
+type Charset func(b []byte) string
+
+// Latin1 decodes ISO 8859-1 text.
+func Latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// UTF8 decodes UTF-8 text, replacing invalid sequences with U+FFFD.
+func UTF8(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	return strings.ToValidUTF8(string(b), "�")
+}
+
+// Encoder encodes text for meta events written by Write. It is the
+// inverse of a Charset, so text written with one encoder reads back
+// unchanged with the matching charset.
+type Encoder func(s string) []byte
+
+// EncodeLatin1 encodes text as ISO 8859-1, the inverse of Latin1.
+// Characters outside it are written as '?'.
+func EncodeLatin1(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			r = '?'
+		}
+		b = append(b, byte(r))
+	}
+	return b
+}
+
+// EncodeUTF8 encodes text as UTF-8, the inverse of UTF8.
+func EncodeUTF8(s string) []byte {
+	return []byte(s)
+}
+
+// decodeText decodes meta event text with the charset of the track.
+func (t *MIDITrack) decodeText(b []byte) string {
+	if t.charset == nil {
+		return Latin1(b)
+	}
+	return t.charset(b)
+}
+
+// TextEvent is a text (FF 01) meta event.
+type TextEvent struct {
+	Tick    int64
+	Raw     []byte // undecoded text
+	Text    string // text decoded with the charset given when reading
+	Channel int    // channel set by a channel prefix, or -1
+}
+
+// TextEvents returns the text (FF 01) meta events of the track.
+func (t *MIDITrack) TextEvents() []TextEvent {
+	var events []TextEvent
+	channels := t.prefixChannels()
+	for _, e := range t.events {
+		if !isMetaEvent(e.message, 0x01) {
+			continue
+		}
+		msg, err := ParseMessage(e.message)
+		if err != nil {
+			continue
+		}
+		raw := msg.(MetaEvent).Data
+		events = append(events, TextEvent{
+			Tick:    e.tick,
+			Raw:     raw,
+			Text:    t.decodeText(raw),
+			Channel: prefixChannel(channels, e),
+		})
+	}
+	return events
+}
+
+// metaString returns the decoded text of the first meta event of the
+// given type in the track.
+func (t *MIDITrack) metaString(typ uint8) (string, bool) {
+	for _, e := range t.events {
+		if !isMetaEvent(e.message, typ) {
+			continue
+		}
+		if data, err := lengthPrefixed(e.message[2:]); err == nil {
+			return t.decodeText(data), true
+		}
+	}
+	return "", false
+}
+
+// ProgramName returns the text of the first program name (FF 08) meta
+// event of the track, which names the patch in use as written by
+// notation programs such as Finale and Sibelius.
+func (t *MIDITrack) ProgramName() (string, bool) {
+	return t.metaString(0x08)
+}
+
+// DeviceName returns the text of the first device name (FF 09) meta
+// event of the track, which names the port or device the track is meant
+// to play on.
+func (t *MIDITrack) DeviceName() (string, bool) {
+	return t.metaString(0x09)
+}
@@ -0,0 +1,85 @@
+package midi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTextEvents(t *testing.T) {
+	track := []byte{
+		0x00, 0xFF, 0x01, 0x04, 'c', 'a', 'f', 0xE9,
+		0x60, 0xFF, 0x01, 0x02, 0x82, 0xA0,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	data := buildSMF(0, 480, track)
+
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := BuildMIDIDataFromMIDIFile(m).At(0).TextEvents()
+	if len(events) != 2 {
+		t.Fatalf("got %d text events, want 2", len(events))
+	}
+	if events[0].Text != "café" || events[0].Tick != 0 {
+		t.Errorf("Latin-1 text = %q at %d", events[0].Text, events[0].Tick)
+	}
+	if !bytes.Equal(events[1].Raw, []byte{0x82, 0xA0}) || events[1].Tick != 96 {
+		t.Errorf("raw text = % X at %d", events[1].Raw, events[1].Tick)
+	}
+
+	// A stand-in for a Shift-JIS decoder that knows a single character.
+	sjis := func(b []byte) string {
+		if bytes.Equal(b, []byte{0x82, 0xA0}) {
+			return "あ"
+		}
+		return Latin1(b)
+	}
+	m, err = ReadWithOptions(bytes.NewReader(data), ReadOptions{Charset: sjis})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events = BuildMIDIDataFromMIDIFile(m).At(0).TextEvents()
+	if events[1].Text != "あ" {
+		t.Errorf("decoded text = %q, want あ", events[1].Text)
+	}
+
+	if got := UTF8([]byte{'a', 0xFF}); got != "a�" {
+		t.Errorf("UTF8 = %q", got)
+	}
+}
+
+func TestProgramAndDeviceName(t *testing.T) {
+	track := []byte{
+		0x00, 0xFF, 0x09, 0x05, 'P', 'o', 'r', 't', '1',
+		0x00, 0xFF, 0x08, 0x05, 'F', 'l', 'u', 't', 'e',
+		0x00, 0xC0, 0x49,
+		0x00, 0xFF, 0x2F, 0x00,
+	}
+	m, err := Read(bytes.NewReader(buildSMF(0, 480, track)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, d, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	m, err = Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := BuildMIDIDataFromMIDIFile(m).At(0)
+	if name, ok := got.ProgramName(); !ok || name != "Flute" {
+		t.Errorf("ProgramName() = %q, %v, want Flute", name, ok)
+	}
+	if name, ok := got.DeviceName(); !ok || name != "Port1" {
+		t.Errorf("DeviceName() = %q, %v, want Port1", name, ok)
+	}
+
+	empty := newTestTrack()
+	if _, ok := empty.ProgramName(); ok {
+		t.Error("ProgramName() of an empty track should fail")
+	}
+}
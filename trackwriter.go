@@ -0,0 +1,188 @@
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxDelta is the largest delta time a four byte variable-length quantity
+// can hold.
+const maxDelta = 0x0FFFFFFF
+
+// TrackWriter streams the events of a single MTrk chunk to an io.Writer,
+// so that large tracks can be written without building MIDIData first.
+// If the writer is an io.WriteSeeker, events are written as they come and
+// the chunk length is filled in on Close; otherwise the chunk is buffered
+// until Close.
+type TrackWriter struct {
+	w      io.Writer
+	seeker io.WriteSeeker // set if the length is patched in place
+	start  int64          // offset of the chunk when seeking
+	out    *bufio.Writer
+	buf    bytes.Buffer
+	length int64
+	ended  bool
+	closed bool
+	err    error
+}
+
+// NewTrackWriter returns a TrackWriter that writes a track chunk to w.
+func NewTrackWriter(w io.Writer) *TrackWriter {
+	t := &TrackWriter{w: w}
+	if s, ok := w.(io.WriteSeeker); ok {
+		// Pipes and terminals may claim to seek but fail.
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			t.seeker, t.start = s, start
+			t.out = bufio.NewWriter(s)
+			t.out.WriteString("MTrk")
+			t.out.Write([]byte{0, 0, 0, 0})
+		}
+	}
+	return t
+}
+
+// WriteEvent adds an event delta ticks after the previous one. msg must
+// include its status byte, as returned by MIDIEvent.Message; running
+// status isn't supported. An end of track event is added by Close if the
+// track doesn't end with one, and no events may follow it.
+func (t *TrackWriter) WriteEvent(delta uint64, msg []byte) error {
+	if t.err != nil {
+		return t.err
+	}
+	if t.closed || t.ended {
+		return errors.New("write after end of track")
+	}
+	if len(msg) == 0 || msg[0] < 0x80 {
+		return errors.New("event without status byte")
+	}
+	if delta > maxDelta {
+		return fmt.Errorf("delta time %d too large", delta)
+	}
+
+	t.write(encodeVarLen(delta))
+	t.write(msg)
+	t.ended = isEndOfTrack(msg)
+	return t.err
+}
+
+// write appends b to the chunk payload.
+func (t *TrackWriter) write(b []byte) {
+	t.length += int64(len(b))
+	if t.out == nil {
+		t.buf.Write(b)
+		return
+	}
+	if _, err := t.out.Write(b); err != nil {
+		t.err = err
+	}
+}
+
+// Close ends the track and completes the chunk. It doesn't close the
+// underlying writer.
+func (t *TrackWriter) Close() error {
+	if t.closed {
+		return t.err
+	}
+	if !t.ended && t.err == nil {
+		t.WriteEvent(0, []byte{0xFF, 0x2F, 0x00})
+	}
+	t.closed = true
+	if t.err != nil {
+		return t.err
+	}
+	if t.length > 0xFFFFFFFF {
+		t.err = errors.New("track too large")
+		return t.err
+	}
+
+	if t.out == nil {
+		var header [8]byte
+		copy(header[:], "MTrk")
+		binary.BigEndian.PutUint32(header[4:], uint32(t.length))
+		if _, t.err = t.w.Write(header[:]); t.err == nil {
+			_, t.err = t.buf.WriteTo(t.w)
+		}
+		return t.err
+	}
+
+	if t.err = t.out.Flush(); t.err != nil {
+		return t.err
+	}
+	if _, t.err = t.seeker.Seek(t.start+4, io.SeekStart); t.err != nil {
+		return t.err
+	}
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(t.length))
+	if _, t.err = t.seeker.Write(length[:]); t.err != nil {
+		return t.err
+	}
+	_, t.err = t.seeker.Seek(t.start+8+t.length, io.SeekStart)
+	return t.err
+}
+
+// FileWriter streams a standard MIDI file one track at a time. The header
+// is written by NewFileWriter, so the number of tracks must be known up
+// front.
+type FileWriter struct {
+	w         io.Writer
+	numTracks int
+	written   int
+	track     *TrackWriter
+}
+
+// NewFileWriter writes the MThd chunk for a file of the given format,
+// number of tracks and division to w and returns a FileWriter to add the
+// tracks.
+func NewFileWriter(w io.Writer, format, numTracks, division int) (*FileWriter, error) {
+	if format < 0 || format > 2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownFormat, format)
+	}
+	if numTracks < 0 || numTracks > 0xFFFF || (format == 0 && numTracks != 1) {
+		return nil, fmt.Errorf("%w: %d tracks in format %d",
+			ErrBadTrackCount, numTracks, format)
+	}
+	if !validDivision(division) {
+		return nil, fmt.Errorf("%w %d", ErrBadDivision, division)
+	}
+
+	var header [14]byte
+	copy(header[:], "MThd")
+	binary.BigEndian.PutUint32(header[4:], 6)
+	binary.BigEndian.PutUint16(header[8:], uint16(format))
+	binary.BigEndian.PutUint16(header[10:], uint16(numTracks))
+	binary.BigEndian.PutUint16(header[12:], uint16(division))
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &FileWriter{w: w, numTracks: numTracks}, nil
+}
+
+// NewTrack starts the next track. The previous track must be closed
+// first.
+func (f *FileWriter) NewTrack() (*TrackWriter, error) {
+	if f.track != nil && !f.track.closed {
+		return nil, errors.New("previous track not closed")
+	}
+	if f.written == f.numTracks {
+		return nil, fmt.Errorf("all %d tracks written", f.numTracks)
+	}
+	f.written++
+	f.track = NewTrackWriter(f.w)
+	return f.track, nil
+}
+
+// Close checks that every track announced in the header has been written
+// and closed. It doesn't close the underlying writer.
+func (f *FileWriter) Close() error {
+	if f.track != nil && !f.track.closed {
+		return errors.New("last track not closed")
+	}
+	if f.written != f.numTracks {
+		return fmt.Errorf("%d of %d tracks written", f.written, f.numTracks)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package midi
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// writeStream writes a two track file with a FileWriter to w.
+func writeStream(t *testing.T, w io.Writer) {
+	f, err := NewFileWriter(w, 1, 2, 480)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conductor, err := f.NewTrack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.NewTrack(); err == nil {
+		t.Error("NewTrack() before closing the previous track should fail")
+	}
+	conductor.WriteEvent(0, []byte{0xFF, 0x51, 0x03, 0x07, 0xA1, 0x20})
+	if err := conductor.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	notes, err := f.NewTrack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		notes.WriteEvent(0, []byte{0x90, 60, 100})
+		notes.WriteEvent(240, []byte{0x80, 60, 0})
+	}
+	notes.WriteEvent(0, []byte{0xFF, 0x2F, 0x00})
+	if err := notes.WriteEvent(0, []byte{0x90, 60, 100}); err == nil {
+		t.Error("WriteEvent() after end of track should fail")
+	}
+	if err := notes.WriteEvent(0, []byte{60, 100}); err == nil {
+		t.Error("WriteEvent() without status byte should fail")
+	}
+	if err := notes.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkStream(t *testing.T, data []byte) {
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := BuildMIDIDataFromMIDIFile(m)
+	if d.Len() != 2 || d.At(0).Len() != 2 || d.At(1).Len() != 201 {
+		t.Fatalf("tracks = %d, want 2 with 2 and 201 events", d.Len())
+	}
+	if got := d.At(1).At(198).Tick(); got != 99*240 {
+		t.Errorf("last note on at %d, want %d", got, 99*240)
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	var buf bytes.Buffer
+	writeStream(t, &buf)
+	checkStream(t, buf.Bytes())
+
+	file, err := ioutil.TempFile("", "trackwriter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	writeStream(t, file)
+	data, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, buf.Bytes()) {
+		t.Error("seeking and buffered writers differ")
+	}
+	checkStream(t, data)
+
+	f, err := NewFileWriter(ioutil.Discard, 1, 2, 480)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err == nil {
+		t.Error("Close() with tracks missing should fail")
+	}
+	if _, err := NewFileWriter(ioutil.Discard, 0, 2, 480); err == nil {
+		t.Error("NewFileWriter() with two tracks in format 0 should fail")
+	}
+}
@@ -0,0 +1,116 @@
+package midi
+
+import (
+	"fmt"
+	"math"
+)
+
+// PercussionChannel is the zero-based channel that General MIDI reserves
+// for percussion (channel 10 in one-based numbering). Keys sent on this
+// channel select drum sounds rather than pitches.
+const PercussionChannel = 9
+
+// TransposeOptions controls which events Transpose touches.
+type TransposeOptions struct {
+	// SkipPercussion leaves events on PercussionChannel untouched, so a
+	// global transpose doesn't turn a kick drum into a snare.
+	SkipPercussion bool
+}
+
+var defaultTransposeOptions = TransposeOptions{
+	SkipPercussion: true,
+}
+
+// Transpose shifts the key of every note on, note off and polyphonic
+// key pressure event by semitones, which may be negative. Keys that would
+// fall outside 0..127 are clamped. If opts is omitted, percussion is
+// skipped.
+func (t *MIDITrack) Transpose(semitones int, opts ...TransposeOptions) {
+	t.mapKeys(func(key int) int { return key + semitones }, opts)
+}
+
+// Invert mirrors the key of every note on, note off and polyphonic key
+// pressure event about axisKey, so that key becomes 2*axisKey - key.
+// Keys that would fall outside 0..127 are clamped. If opts is omitted,
+// percussion is skipped.
+func (t *MIDITrack) Invert(axisKey int, opts ...TransposeOptions) {
+	t.mapKeys(func(key int) int { return 2*axisKey - key }, opts)
+}
+
+// FitToRange transposes the track by whole octaves so that all of its
+// notes lie within low..high, choosing the smallest shift that works.
+// Notes on PercussionChannel are ignored and left as they are. It returns
+// an error, leaving the track unchanged, if no octave shift fits.
+func (t *MIDITrack) FitToRange(low, high int) error {
+	lowest, highest := 128, -1
+	for _, e := range t.events {
+		ch, key, on, ok := noteEvent(e.message)
+		if !ok || !on || ch == PercussionChannel {
+			continue
+		}
+		if key < lowest {
+			lowest = key
+		}
+		if key > highest {
+			highest = key
+		}
+	}
+	if highest < 0 {
+		return nil
+	}
+
+	up := int(math.Ceil(float64(low-lowest) / 12))
+	down := int(math.Floor(float64(high-highest) / 12))
+	if up > down {
+		return fmt.Errorf("notes %d to %d don't fit in %d to %d by octaves",
+			lowest, highest, low, high)
+	}
+	octaves := 0
+	if up > 0 {
+		octaves = up
+	} else if down < 0 {
+		octaves = down
+	}
+	t.Transpose(12 * octaves)
+	return nil
+}
+
+// mapKeys replaces the key of every note on, note off and polyphonic key
+// pressure event with fn(key), clamped to 0..127.
+func (t *MIDITrack) mapKeys(fn func(int) int, opts []TransposeOptions) {
+	o := defaultTransposeOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+
+	for _, e := range t.events {
+		ch, ok := channelOf(e.message)
+		if !ok || len(e.message) < 2 {
+			continue
+		}
+		if o.SkipPercussion && ch == PercussionChannel {
+			continue
+		}
+		switch e.message[0] & 0xF0 {
+		case 0x80, 0x90, 0xA0:
+			e.message[1] = clampDataByte(fn(int(e.message[1])))
+		}
+	}
+}
+
+// Transpose transposes every track. See (*MIDITrack).Transpose.
+func (d *MIDIData) Transpose(semitones int, opts ...TransposeOptions) {
+	for _, t := range d.tracks {
+		t.Transpose(semitones, opts...)
+	}
+}
+
+func clampDataByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 127 {
+		return 127
+	}
+	return uint8(v)
+}
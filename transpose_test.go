@@ -0,0 +1,115 @@
+package midi
+
+import (
+	"testing"
+)
+
+func newTestTrack(events ...*MIDIEvent) *MIDITrack {
+	t := &MIDITrack{}
+	for _, e := range events {
+		t.Append(e)
+	}
+	return t
+}
+
+func TestTranspose(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 10, message: []uint8{0x80, 60, 0}},
+		&MIDIEvent{tick: 10, message: []uint8{0x89, 36, 0}},
+	)
+
+	track.Transpose(-2)
+	if k := track.At(0).Message()[1]; k != 58 {
+		t.Errorf("note on key = %d, want 58", k)
+	}
+	if k := track.At(2).Message()[1]; k != 58 {
+		t.Errorf("note off key = %d, want 58", k)
+	}
+	if k := track.At(1).Message()[1]; k != 36 {
+		t.Errorf("percussion key = %d, want 36 (untouched)", k)
+	}
+
+	track.Transpose(2, TransposeOptions{SkipPercussion: false})
+	if k := track.At(0).Message()[1]; k != 60 {
+		t.Errorf("note on key = %d, want 60", k)
+	}
+	if k := track.At(1).Message()[1]; k != 38 {
+		t.Errorf("percussion key = %d, want 38", k)
+	}
+}
+
+func TestTransposeClamp(t *testing.T) {
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 120, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 5, 100}},
+	)
+
+	track.Transpose(12)
+	if k := track.At(0).Message()[1]; k != 127 {
+		t.Errorf("key = %d, want 127", k)
+	}
+	track.Transpose(-24)
+	if k := track.At(1).Message()[1]; k != 0 {
+		t.Errorf("key = %d, want 0", k)
+	}
+}
+
+func TestInvert(t *testing.T) {
+	// C major triad and a G inverted about C4 (60).
+	track := newTestTrack(
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 64, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 67, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+		&MIDIEvent{tick: 0, message: []uint8{0x90, 5, 100}},
+		&MIDIEvent{tick: 10, message: []uint8{0x80, 67, 0}},
+	)
+	track.Invert(60)
+
+	want := []uint8{60, 56, 53, 36, 115, 53}
+	for i, key := range want {
+		if got := track.At(i).Message()[1]; got != key {
+			t.Errorf("event %d: key %d, want %d", i, got, key)
+		}
+	}
+
+	track.Invert(100, TransposeOptions{})
+	if got := track.At(3).Message()[1]; got != 127 {
+		t.Errorf("drum key %d, want 127 (clamped)", got)
+	}
+}
+
+func TestFitToRange(t *testing.T) {
+	newBass := func() *MIDITrack {
+		return newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 28, 100}},
+			&MIDIEvent{tick: 0, message: []uint8{0x99, 36, 100}},
+			&MIDIEvent{tick: 10, message: []uint8{0x80, 28, 0}},
+			&MIDIEvent{tick: 10, message: []uint8{0x90, 40, 100}},
+			&MIDIEvent{tick: 20, message: []uint8{0x80, 40, 0}},
+		)
+	}
+
+	track := newBass()
+	if err := track.FitToRange(48, 72); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint8{52, 36, 52, 64, 64} {
+		if got := track.At(i).Message()[1]; got != want {
+			t.Errorf("event %d: key %d, want %d", i, got, want)
+		}
+	}
+
+	track = newBass()
+	if err := track.FitToRange(0, 127); err != nil || track.At(0).Message()[1] != 28 {
+		t.Errorf("FitToRange(0, 127) = %v, moved key to %d", err, track.At(0).Message()[1])
+	}
+	if err := track.FitToRange(48, 55); err == nil {
+		t.Error("FitToRange(48, 55) fitted a 12 semitone span")
+	}
+	if got := track.At(0).Message()[1]; got != 28 {
+		t.Errorf("failed FitToRange moved key to %d", got)
+	}
+}
@@ -0,0 +1,101 @@
+package midi
+
+import (
+	"math"
+)
+
+// QuantizeVelocity maps the velocity of every note on to the nearest of
+// levels evenly spaced values from 1 to 127, for gear that only
+// distinguishes a few velocities. With a single level every note gets
+// velocity 127. Note offs, including note ons with zero velocity, are left
+// untouched. It does nothing if levels is less than 1.
+func (t *MIDITrack) QuantizeVelocity(levels int) {
+	if levels < 1 {
+		return
+	}
+
+	for _, e := range t.events {
+		if _, _, on, ok := noteEvent(e.message); !ok || !on {
+			continue
+		}
+		if levels == 1 {
+			e.message[2] = 127
+			continue
+		}
+		step := 126 / float64(levels-1)
+		i := math.Floor((float64(e.message[2])-1)/step + 0.5)
+		e.message[2] = uint8(1 + math.Floor(i*step+0.5))
+	}
+}
+
+// VelocityCurve is a velocity response: how a device turns note on
+// velocity into amplitude.
+type VelocityCurve int
+
+const (
+	// LinearCurve makes amplitude proportional to velocity.
+	LinearCurve VelocityCurve = iota
+	// GMCurve is the response recommended by General MIDI, a gain of
+	// 40 log10(velocity/127) dB, i.e. amplitude proportional to the
+	// square of velocity. See GMVelocityGain.
+	GMCurve
+	// SCurve is soft at both ends and steep in the middle, following
+	// the smoothstep function of velocity/127.
+	SCurve
+)
+
+// GMVelocityGain is the amplitude, from 0 to 1, that the General MIDI
+// velocity response gives each velocity.
+var GMVelocityGain = func() [128]float64 {
+	var table [128]float64
+	for v := range table {
+		x := float64(v) / 127
+		table[v] = x * x
+	}
+	return table
+}()
+
+// Gain returns the amplitude, from 0 to 1, that the curve gives velocity,
+// which is clamped to 0..127.
+func (c VelocityCurve) Gain(velocity int) float64 {
+	x := float64(clampDataByte(velocity)) / 127
+	switch c {
+	case GMCurve:
+		return GMVelocityGain[clampDataByte(velocity)]
+	case SCurve:
+		return x * x * (3 - 2*x)
+	}
+	return x
+}
+
+// velocity returns the velocity from 1 to 127 whose gain on the curve is
+// nearest to gain.
+func (c VelocityCurve) velocity(gain float64) uint8 {
+	best, bestErr := 1, math.Inf(1)
+	for v := 1; v <= 127; v++ {
+		if e := math.Abs(c.Gain(v) - gain); e < bestErr {
+			best, bestErr = v, e
+		}
+	}
+	return uint8(best)
+}
+
+// RemapVelocityCurve translates the note on velocities of the track,
+// authored for a device with the from response, to the velocities that
+// give the same amplitude on a device with the to response, e.g. to move
+// a performance from a soft synth to hardware. Note offs, including note
+// ons with zero velocity, are left untouched.
+func (t *MIDITrack) RemapVelocityCurve(from, to VelocityCurve) {
+	if from == to {
+		return
+	}
+	var table [128]uint8
+	for v := 1; v <= 127; v++ {
+		table[v] = to.velocity(from.Gain(v))
+	}
+	for _, e := range t.events {
+		if _, _, on, ok := noteEvent(e.message); ok && on {
+			e.message[2] = table[e.message[2]]
+		}
+	}
+}
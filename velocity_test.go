@@ -0,0 +1,79 @@
+package midi
+
+import (
+	"testing"
+)
+
+func TestQuantizeVelocity(t *testing.T) {
+	velocities := []uint8{1, 20, 40, 64, 100, 127}
+	newTrack := func() *MIDITrack {
+		track := newTestTrack()
+		for _, v := range velocities {
+			track.Append(&MIDIEvent{message: []uint8{0x90, 60, v}})
+		}
+		track.Append(&MIDIEvent{message: []uint8{0x90, 60, 0}})
+		track.Append(&MIDIEvent{message: []uint8{0x80, 60, 33}})
+		return track
+	}
+
+	cases := []struct {
+		levels int
+		want   []uint8
+	}{
+		{1, []uint8{127, 127, 127, 127, 127, 127}},
+		{2, []uint8{1, 1, 1, 127, 127, 127}},
+		{3, []uint8{1, 1, 64, 64, 127, 127}},
+		{127, velocities},
+	}
+	for _, c := range cases {
+		track := newTrack()
+		track.QuantizeVelocity(c.levels)
+		for i, want := range c.want {
+			if got := track.At(i).Message()[2]; got != want {
+				t.Errorf("levels %d: velocity %d -> %d, want %d",
+					c.levels, velocities[i], got, want)
+			}
+		}
+		n := track.Len()
+		if track.At(n - 2).Message()[2] != 0 || track.At(n - 1).Message()[2] != 33 {
+			t.Errorf("levels %d: note offs were modified", c.levels)
+		}
+	}
+}
+
+func TestRemapVelocityCurve(t *testing.T) {
+	newTrack := func() *MIDITrack {
+		return newTestTrack(
+			&MIDIEvent{message: []uint8{0x90, 60, 64}},
+			&MIDIEvent{message: []uint8{0x90, 62, 127}},
+			&MIDIEvent{message: []uint8{0x90, 60, 0}},
+			&MIDIEvent{message: []uint8{0x80, 62, 64}},
+		)
+	}
+	cases := []struct {
+		from, to VelocityCurve
+		want     []uint8
+	}{
+		{LinearCurve, GMCurve, []uint8{90, 127, 0, 64}},
+		{GMCurve, LinearCurve, []uint8{32, 127, 0, 64}},
+		{SCurve, SCurve, []uint8{64, 127, 0, 64}},
+	}
+	for _, c := range cases {
+		track := newTrack()
+		track.RemapVelocityCurve(c.from, c.to)
+		for i, want := range c.want {
+			if got := track.At(i).Message()[2]; got != want {
+				t.Errorf("RemapVelocityCurve(%d, %d): event %d velocity = %d, want %d",
+					c.from, c.to, i, got, want)
+			}
+		}
+	}
+
+	if GMVelocityGain[0] != 0 || GMVelocityGain[127] != 1 {
+		t.Errorf("GMVelocityGain ends = %g, %g, want 0, 1",
+			GMVelocityGain[0], GMVelocityGain[127])
+	}
+	if got := SCurve.Gain(127) - SCurve.Gain(0); got != 1 {
+		t.Errorf("SCurve range = %g, want 1", got)
+	}
+}
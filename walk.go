@@ -0,0 +1,36 @@
+package midi
+
+// Walk calls fn for every event of every track in tick order, stopping
+// early if fn returns false. Events at the same tick are visited meta and
+// system exclusive events first, then in track order; the order of events
+// within a track is always preserved. No intermediate slice is built, so
+// it is cheaper than merging the tracks.
+func (d *MIDIData) Walk(fn func(track int, e *MIDIEvent) bool) {
+	cursors := make([]int, len(d.tracks))
+	for {
+		best := -1
+		var bestTick int64
+		var bestMeta bool
+		for i, t := range d.tracks {
+			if cursors[i] >= len(t.events) {
+				continue
+			}
+			e := t.events[cursors[i]]
+			_, channel := channelOf(e.message)
+			meta := !channel
+			if best < 0 || e.tick < bestTick ||
+				(e.tick == bestTick && meta && !bestMeta) {
+				best, bestTick, bestMeta = i, e.tick, meta
+			}
+		}
+		if best < 0 {
+			return
+		}
+
+		e := d.tracks[best].events[cursors[best]]
+		cursors[best]++
+		if !fn(best, e) {
+			return
+		}
+	}
+}
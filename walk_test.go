@@ -0,0 +1,54 @@
+package midi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	d := newTestData(480,
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: tempoMessage(120)},
+			&MIDIEvent{tick: 480, message: tempoMessage(90)},
+			&MIDIEvent{tick: 960, message: []uint8{0xFF, 0x2F, 0x00}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0x90, 60, 100}},
+			&MIDIEvent{tick: 480, message: []uint8{0x80, 60, 0}},
+			&MIDIEvent{tick: 480, message: []uint8{0xFF, 0x01, 0x01, 'x'}},
+		),
+		newTestTrack(
+			&MIDIEvent{tick: 0, message: []uint8{0xFF, 0x03, 0x01, 'c'}},
+			&MIDIEvent{tick: 240, message: []uint8{0x91, 64, 100}},
+		),
+	)
+
+	type visit struct {
+		track int
+		tick  int64
+	}
+	var got []visit
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		got = append(got, visit{track, e.Tick()})
+		return true
+	})
+
+	want := []visit{
+		{0, 0}, {2, 0}, {1, 0},
+		{2, 240},
+		{0, 480}, {1, 480}, {1, 480},
+		{0, 960},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Walk visited %v, want %v", got, want)
+	}
+
+	n := 0
+	d.Walk(func(track int, e *MIDIEvent) bool {
+		n++
+		return n < 3
+	})
+	if n != 3 {
+		t.Errorf("Walk didn't stop early: %d calls", n)
+	}
+}